@@ -17,6 +17,22 @@ type Config struct {
 	RedisPort  string
 	QueueHost  string
 	QueuePort  string
+	// CacheDriver selects the product cache: "redis" (default), "memory" or
+	// "tiered" (in-process LRU in front of Redis).
+	CacheDriver string
+	// CacheWriteStrategy is "invalidate" (default) or "write-through".
+	CacheWriteStrategy string
+	// AdminToken must be sent in the X-Admin-Token header to reach /admin
+	// endpoints. They are disabled when it is empty.
+	AdminToken string
+	// SearchLanguage is the default Postgres text search configuration, e.g.
+	// "english". It should match the default of products.search_language.
+	SearchLanguage string
+	// S3Bucket holds the compressed product images.
+	S3Bucket string
+	// TrashRetentionDays is how many days deleted products stay in the trash
+	// before they are purged, 30 by default.
+	TrashRetentionDays string
 }
 
 func LoadConfig() (*Config, error) {
@@ -26,15 +42,21 @@ func LoadConfig() (*Config, error) {
 	}
 
 	config := &Config{
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBName:     os.Getenv("DB_NAME"),
-		RedisHost:  os.Getenv("REDIS_HOST"),
-		RedisPort:  os.Getenv("REDIS_PORT"),
-		QueueHost:  os.Getenv("QUEUE_HOST"),
-		QueuePort:  os.Getenv("QUEUE_PORT"),
+		DBHost:             os.Getenv("DB_HOST"),
+		DBPort:             os.Getenv("DB_PORT"),
+		DBUser:             os.Getenv("DB_USER"),
+		DBPassword:         os.Getenv("DB_PASSWORD"),
+		DBName:             os.Getenv("DB_NAME"),
+		RedisHost:          os.Getenv("REDIS_HOST"),
+		RedisPort:          os.Getenv("REDIS_PORT"),
+		QueueHost:          os.Getenv("QUEUE_HOST"),
+		QueuePort:          os.Getenv("QUEUE_PORT"),
+		CacheDriver:        os.Getenv("CACHE_DRIVER"),
+		CacheWriteStrategy: os.Getenv("CACHE_WRITE_STRATEGY"),
+		AdminToken:         os.Getenv("ADMIN_TOKEN"),
+		SearchLanguage:     os.Getenv("SEARCH_LANGUAGE"),
+		S3Bucket:           os.Getenv("S3_BUCKET"),
+		TrashRetentionDays: os.Getenv("TRASH_RETENTION_DAYS"),
 	}
 
 	return config, nil
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// parseActor reads the ID of the user making the request from the X-User-ID
+// header. It returns zero when the header is missing. The header is trusted
+// as is, it only attributes changes.
+func parseActor(r *http.Request) (int, error) {
+	v := r.Header.Get("X-User-ID")
+	if v == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 1 {
+		return 0, errors.New("Invalid X-User-ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// CachedProductResponse describes a product cache entry for GET
+// /admin/cache/products/{id}.
+type CachedProductResponse struct {
+	Product    models.Product `json:"product"`
+	FreshUntil string         `json:"fresh_until"`
+	Fresh      bool           `json:"fresh"`
+}
+
+// WarmCacheRequest lists the products to load into the cache.
+type WarmCacheRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// WarmCacheResponse reports which products were cached and which don't exist.
+type WarmCacheResponse struct {
+	Warmed  []int `json:"warmed"`
+	Missing []int `json:"missing"`
+}
+
+func (h *Handler) GetCachedProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := h.Cache.GetProductByID(id)
+	if err == services.ErrCacheMiss {
+		http.Error(w, "Product not cached", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to read cache", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(CachedProductResponse{
+		Product:    entry.Product,
+		FreshUntil: entry.FreshUntil.UTC().Format(http.TimeFormat),
+		Fresh:      entry.Fresh(),
+	})
+}
+
+func (h *Handler) PurgeCachedProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	err = services.InvalidateProduct(h.Cache, h.Invalidations, id, 0)
+	if err != nil {
+		http.Error(w, "Failed to purge product", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) PurgeCachedUserProducts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = services.InvalidateUserProductLists(h.Cache, h.Invalidations, userID)
+	if err != nil {
+		http.Error(w, "Failed to purge user listings", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) WarmProductCache(w http.ResponseWriter, r *http.Request) {
+	var req WarmCacheRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	resp := WarmCacheResponse{Warmed: []int{}, Missing: []int{}}
+	for _, id := range req.IDs {
+		var product models.Product
+		err := product.GetByID(h.DB, id)
+		if err != nil {
+			resp.Missing = append(resp.Missing, id)
+			continue
+		}
+
+		err = h.Cache.SetProductByID(id, product)
+		if err != nil {
+			http.Error(w, "Failed to write cache", http.StatusInternalServerError)
+			return
+		}
+		resp.Warmed = append(resp.Warmed, id)
+	}
+
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	services.CacheStats.WritePrometheus(w)
+}
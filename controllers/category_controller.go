@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// ProductCategoriesRequest replaces the categories of a product.
+type ProductCategoriesRequest struct {
+	CategoryIDs []int `json:"category_ids"`
+}
+
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var category models.Category
+	err := json.NewDecoder(r.Body).Decode(&category)
+	if err != nil || strings.TrimSpace(category.Name) == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	err = category.Create(h.DB)
+	if err != nil {
+		writeCategoryError(w, err, "Failed to create category")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *Handler) GetCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := models.GetAllCategories(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *Handler) GetCategoryByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	var category models.Category
+	err = category.GetByID(h.DB, id)
+	if err != nil {
+		writeCategoryError(w, err, "Failed to get category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	var category models.Category
+	err = json.NewDecoder(r.Body).Decode(&category)
+	if err != nil || strings.TrimSpace(category.Name) == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	category.ID = id
+
+	err = category.Update(h.DB)
+	if err != nil {
+		writeCategoryError(w, err, "Failed to update category")
+		return
+	}
+
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	category := models.Category{ID: id}
+	err = category.Delete(h.DB)
+	if err != nil {
+		writeCategoryError(w, err, "Failed to delete category")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetProductCategories(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	categories, err := models.GetProductCategories(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product categories", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *Handler) SetProductCategories(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ProductCategoriesRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.SetProductCategories(h.DB, id, req.CategoryIDs)
+	if err != nil {
+		writeCategoryError(w, err, "Failed to set product categories")
+		return
+	}
+
+	// Listings filtered or faceted by category have changed
+	services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+
+	categories, err := models.GetProductCategories(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product categories", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(categories)
+}
+
+// writeCategoryError responds with the status matching a category error, or
+// 500 with msg.
+func writeCategoryError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrCategoryNotFound):
+		http.Error(w, "Category not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrUnknownCategory):
+		http.Error(w, "Unknown category", http.StatusBadRequest)
+	case errors.Is(err, models.ErrCategoryCycle):
+		http.Error(w, "Category cannot be moved below itself", http.StatusConflict)
+	case errors.Is(err, models.ErrCategoryExists):
+		http.Error(w, "Category already exists", http.StatusConflict)
+	case errors.Is(err, models.ErrCategoryHasChildren):
+		http.Error(w, "Category has subcategories", http.StatusConflict)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
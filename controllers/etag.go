@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+// productETag identifies one representation of a product: its version,
+// which If-Match is checked against, and a hash of the body, which also
+// changes with promotions, exchange rates and the requested currency.
+func productETag(version int, body []byte) string {
+	h := fnv.New64a()
+	h.Write(body)
+	return fmt.Sprintf(`"%d-%x"`, version, h.Sum64())
+}
+
+// etagVersion returns the product version a strong entity tag was made for.
+func etagVersion(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	tag = tag[1 : len(tag)-1]
+	if i := strings.IndexByte(tag, '-'); i >= 0 {
+		tag = tag[:i]
+	}
+	version, err := strconv.Atoi(tag)
+	return version, err == nil
+}
+
+// checkIfMatch makes changes to a product conditional on the If-Match
+// header naming its current version. It writes a 428 response if the header
+// is missing and a 412 response if it doesn't match.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		http.Error(w, "If-Match header required", http.StatusPreconditionRequired)
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if v, ok := etagVersion(tag); ok && v == version {
+			return true
+		}
+	}
+	http.Error(w, "Product was modified", http.StatusPreconditionFailed)
+	return false
+}
+
+// ifNoneMatch reports whether an If-None-Match header matches etag. Weak
+// tags match too, as they do for GET requests.
+func ifNoneMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeProduct writes product along with its ETag. GET requests whose
+// If-None-Match header already has the ETag get an empty 304 response.
+func writeProduct(w http.ResponseWriter, r *http.Request, product models.Product) {
+	body, err := json.Marshal(product)
+	if err != nil {
+		http.Error(w, "Failed to encode product", http.StatusInternalServerError)
+		return
+	}
+
+	etag := productETag(product.Version, body)
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet && ifNoneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+func (h *Handler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := models.GetExchangeRates(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to get exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rates)
+}
+
+// SetExchangeRates loads exchange rates from a JSON array of rates or, with
+// Content-Type text/csv, from "base,quote,rate" lines.
+func (h *Handler) SetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	var rates []models.ExchangeRate
+	var err error
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/csv" {
+		rates, err = models.ParseExchangeRatesCSV(r.Body)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&rates)
+	}
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	for i := range rates {
+		err := rates[i].Validate()
+		if err != nil {
+			http.Error(w, "Invalid exchange rate: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = models.SetExchangeRates(h.DB, rates)
+	if err != nil {
+		http.Error(w, "Failed to set exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	rates, err = models.GetExchangeRates(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to get exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rates)
+}
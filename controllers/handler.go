@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"database/sql"
+
+	"github.com/yourusername/yourproject/queue"
+	"github.com/yourusername/yourproject/services"
+)
+
+// Handler serves the API. Its dependencies are built in main and passed in,
+// so that tests can run it against an in-memory cache instead of Redis.
+type Handler struct {
+	DB    *sql.DB
+	Cache services.ProductCache
+	// Invalidations, if set, is used to tell other API instances to evict
+	// products this one changed
+	Invalidations *services.InvalidationBus
+	// Queue receives the images of new products. They aren't compressed
+	// when it is nil.
+	Queue *queue.Queue
+}
+
+func NewHandler(db *sql.DB, cache services.ProductCache, queue *queue.Queue) *Handler {
+	return &Handler{
+		DB:    db,
+		Cache: cache,
+		Queue: queue,
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/queue"
+)
+
+// BackfillImagesResponse is returned by POST /admin/images/backfill.
+type BackfillImagesResponse struct {
+	Enqueued int `json:"enqueued"`
+}
+
+// BackfillImages enqueues the images of products missing compressed images
+// on the bulk lane, so that they don't delay images of new products.
+func (h *Handler) BackfillImages(w http.ResponseWriter, r *http.Request) {
+	if h.Queue == nil {
+		http.Error(w, "Image queue unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	images, err := models.GetUncompressedImages(h.DB, limit)
+	if err != nil {
+		http.Error(w, "Failed to get images", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.Queue.AddProductImagesWithPriority(images, queue.PriorityBulk)
+	if err != nil {
+		http.Error(w, "Failed to add images to queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(BackfillImagesResponse{Enqueued: len(images)})
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// StockAdjustmentRequest changes the units on hand of a product, or of one
+// of its variants if VariantID is set.
+type StockAdjustmentRequest struct {
+	VariantID int    `json:"variant_id"`
+	Delta     int    `json:"delta"`
+	Reason    string `json:"reason"`
+	Note      string `json:"note"`
+}
+
+// LowStockThresholdRequest sets the low stock threshold of a product or
+// variant.
+type LowStockThresholdRequest struct {
+	VariantID         int `json:"variant_id"`
+	LowStockThreshold int `json:"low_stock_threshold"`
+}
+
+// ReservationRequest holds units of a product or variant.
+type ReservationRequest struct {
+	VariantID int `json:"variant_id"`
+	Quantity  int `json:"quantity"`
+}
+
+func (h *Handler) GetProductStock(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	stock, err := models.GetProductStock(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get stock", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(stock)
+}
+
+func (h *Handler) SetLowStockThreshold(w http.ResponseWriter, r *http.Request) {
+	var req LowStockThresholdRequest
+	product, ok := h.decodeInventoryRequest(w, r, &req, &req.VariantID)
+	if !ok {
+		return
+	}
+
+	stock, err := models.SetLowStockThreshold(h.DB, product.ID, req.VariantID, req.LowStockThreshold)
+	if err != nil {
+		writeInventoryError(w, err, "Failed to set low stock threshold")
+		return
+	}
+	services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+
+	json.NewEncoder(w).Encode(stock)
+}
+
+func (h *Handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	var req StockAdjustmentRequest
+	product, ok := h.decodeInventoryRequest(w, r, &req, &req.VariantID)
+	if !ok {
+		return
+	}
+
+	stock, err := models.AdjustStock(h.DB, product.ID, req.VariantID, req.Delta, req.Reason, req.Note)
+	if err != nil {
+		writeInventoryError(w, err, "Failed to adjust stock")
+		return
+	}
+	services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+
+	json.NewEncoder(w).Encode(stock)
+}
+
+func (h *Handler) GetStockAdjustments(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	adjustments, err := models.GetStockAdjustments(h.DB, id, limit)
+	if err != nil {
+		http.Error(w, "Failed to get stock adjustments", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(adjustments)
+}
+
+func (h *Handler) ReserveStock(w http.ResponseWriter, r *http.Request) {
+	var req ReservationRequest
+	product, ok := h.decodeInventoryRequest(w, r, &req, &req.VariantID)
+	if !ok {
+		return
+	}
+
+	reservation, err := models.ReserveStock(h.DB, product.ID, req.VariantID, req.Quantity)
+	if err != nil {
+		writeInventoryError(w, err, "Failed to reserve stock")
+		return
+	}
+	services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+func (h *Handler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	h.settleReservation(w, r, models.ReleaseReservation)
+}
+
+func (h *Handler) CommitReservation(w http.ResponseWriter, r *http.Request) {
+	h.settleReservation(w, r, models.CommitReservation)
+}
+
+func (h *Handler) settleReservation(w http.ResponseWriter, r *http.Request, settle func(db *sql.DB, id int) (*models.Reservation, error)) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	reservation, err := settle(h.DB, id)
+	if err != nil {
+		writeInventoryError(w, err, "Failed to settle reservation")
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, reservation.ProductID)
+	if err == nil {
+		services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+	}
+
+	json.NewEncoder(w).Encode(reservation)
+}
+
+// decodeInventoryRequest decodes the body into req and loads the product of
+// the request path, checking that the variant, if any, belongs to it. It
+// responds with an error and returns false if anything is wrong.
+func (h *Handler) decodeInventoryRequest(w http.ResponseWriter, r *http.Request, req interface{}, variantID *int) (models.Product, bool) {
+	var product models.Product
+
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return product, false
+	}
+
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return product, false
+	}
+
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return product, false
+	}
+
+	if *variantID != 0 {
+		var variant models.Variant
+		err = variant.GetByID(h.DB, id, *variantID)
+		if err != nil {
+			writeVariantError(w, err, "Failed to get variant")
+			return product, false
+		}
+	}
+
+	return product, true
+}
+
+// writeInventoryError responds with the status matching an inventory error,
+// or 500 with msg.
+func writeInventoryError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrInsufficientStock):
+		http.Error(w, "Insufficient stock", http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidQuantity):
+		http.Error(w, "Invalid quantity", http.StatusBadRequest)
+	case errors.Is(err, models.ErrInvalidReason):
+		http.Error(w, "Invalid reason", http.StatusBadRequest)
+	case errors.Is(err, models.ErrReservationNotFound):
+		http.Error(w, "Reservation not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrReservationNotPending):
+		http.Error(w, "Reservation is no longer pending", http.StatusConflict)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+// parseProductFilter reads the filter parameters shared by the product
+// listing endpoints. The caller decides how user_id is scoped.
+func parseProductFilter(r *http.Request) (models.ProductFilter, error) {
+	query := r.URL.Query()
+	filter := models.ProductFilter{
+		ProductName: query.Get("product_name"),
+		Description: query.Get("description"),
+	}
+
+	var err error
+	if v := query.Get("min_price"); v != "" {
+		filter.MinPrice, err = decimal.NewFromString(v)
+		if err != nil {
+			return filter, errors.New("Invalid min_price")
+		}
+	}
+	if v := query.Get("max_price"); v != "" {
+		filter.MaxPrice, err = decimal.NewFromString(v)
+		if err != nil {
+			return filter, errors.New("Invalid max_price")
+		}
+	}
+
+	if v := query.Get("has_images"); v != "" {
+		hasImages, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("Invalid has_images")
+		}
+		filter.HasImages = &hasImages
+	}
+
+	if v := query.Get("created_after"); v != "" {
+		filter.CreatedAfter, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errors.New("Invalid created_after")
+		}
+	}
+	if v := query.Get("created_before"); v != "" {
+		filter.CreatedBefore, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errors.New("Invalid created_before")
+		}
+	}
+
+	if v := query.Get("ids"); v != "" {
+		filter.IDs = []int{}
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return filter, errors.New("Invalid ids")
+			}
+			filter.IDs = append(filter.IDs, id)
+		}
+	}
+
+	if v := query.Get("tags"); v != "" {
+		filter.Tags, err = models.NormalizeTags(strings.Split(v, ","))
+		if err != nil {
+			return filter, errors.New("Invalid tags")
+		}
+	}
+	switch query.Get("tag_match") {
+	case "", "any":
+	case "all":
+		filter.AllTags = true
+	default:
+		return filter, errors.New("Invalid tag_match")
+	}
+
+	if v := query.Get("low_stock"); v != "" {
+		filter.LowStock, err = strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("Invalid low_stock")
+		}
+	}
+
+	if v := query.Get("category_id"); v != "" {
+		filter.CategoryID, err = strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("Invalid category_id")
+		}
+	}
+
+	return filter, nil
+}
+
+// scopeToViewer limits filter to published products unless the viewer, as
+// given by X-User-ID, owns every product it can match. Owners may pick a
+// status with the status parameter. It reports whether the viewer is the
+// owner.
+func scopeToViewer(r *http.Request, filter *models.ProductFilter) (bool, error) {
+	actorID, err := parseActor(r)
+	if err != nil {
+		return false, err
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.ValidProductStatus(status) {
+		return false, errors.New("Invalid status")
+	}
+
+	owner := actorID != 0 && actorID == filter.UserID
+	if !owner {
+		status = models.ProductPublished
+	}
+	filter.Status = status
+	return owner, nil
+}
+
+// parsePageOptions reads the limit, cursor, sort, include_total and facet
+// listing parameters.
+func parsePageOptions(r *http.Request) (models.PageOptions, error) {
+	query := r.URL.Query()
+	page := models.PageOptions{
+		Cursor:       query.Get("cursor"),
+		IncludeTotal: query.Get("include_total") == "true",
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return page, errors.New("Invalid limit")
+		}
+		page.Limit = n
+	}
+
+	sort, err := models.ParseProductSort(query.Get("sort"))
+	if err != nil {
+		return page, errors.New("Invalid sort")
+	}
+	page.Sort = sort
+
+	page.Facets, err = parseFacetOptions(r)
+	if err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// parseFacetOptions reads the facets and price_buckets parameters. It
+// returns nil unless facets=true.
+func parseFacetOptions(r *http.Request) (*models.FacetOptions, error) {
+	query := r.URL.Query()
+	if query.Get("facets") != "true" {
+		return nil, nil
+	}
+
+	opts := &models.FacetOptions{}
+	if v := query.Get("price_buckets"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			boundary, err := decimal.NewFromString(strings.TrimSpace(s))
+			if err != nil {
+				return nil, errors.New("Invalid price_buckets")
+			}
+			opts.PriceBuckets = append(opts.PriceBuckets, boundary)
+		}
+		if opts.Validate() != nil {
+			return nil, errors.New("Invalid price_buckets")
+		}
+	}
+
+	return opts, nil
+}
+
+// parseCurrency reads the currency and rounding parameters. The currency is
+// empty unless prices should be converted.
+func parseCurrency(r *http.Request) (string, models.Rounding, error) {
+	query := r.URL.Query()
+
+	rounding, err := models.ParseRounding(query.Get("rounding"))
+	if err != nil {
+		return "", "", errors.New("Invalid rounding")
+	}
+
+	currency := query.Get("currency")
+	if currency == "" {
+		return "", rounding, nil
+	}
+	currency, err = models.NormalizeCurrency(currency)
+	if err != nil {
+		return "", "", errors.New("Unknown currency")
+	}
+	return currency, rounding, nil
+}
+
+// convertProducts converts the prices of products into currency, if one was
+// asked for, and responds with an error and returns false if that fails.
+func (h *Handler) convertProducts(w http.ResponseWriter, products []*models.Product, currency string, rounding models.Rounding) bool {
+	if currency == "" {
+		return true
+	}
+
+	err := models.ConvertProducts(h.DB, products, currency, rounding)
+	if errors.Is(err, models.ErrNoExchangeRate) {
+		http.Error(w, "No exchange rate to "+currency, http.StatusUnprocessableEntity)
+		return false
+	} else if err != nil {
+		http.Error(w, "Failed to convert prices", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+func (h *Handler) GetProductPrices(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	prices, err := models.GetProductPrices(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get prices", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(prices)
+}
+
+func (h *Handler) SetProductPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var price models.Price
+	err = json.NewDecoder(r.Body).Decode(&price)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	price.Currency = vars["currency"]
+	err = price.Validate()
+	if errors.Is(err, models.ErrUnknownCurrency) {
+		http.Error(w, "Unknown currency", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Invalid price", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.SetProductPrice(h.DB, product, price)
+	if errors.Is(err, models.ErrBaseCurrencyPrice) {
+		http.Error(w, "Use PUT /products/{id} to change the price in the product's currency", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to set price", http.StatusInternalServerError)
+		return
+	}
+
+	// The product is cached with its prices
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	json.NewEncoder(w).Encode(price)
+}
+
+func (h *Handler) DeleteProductPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	currency, err := models.NormalizeCurrency(vars["currency"])
+	if err != nil {
+		http.Error(w, "Unknown currency", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.DeleteProductPrice(h.DB, id, currency)
+	if errors.Is(err, models.ErrPriceNotFound) {
+		http.Error(w, "Price not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to delete price", http.StatusInternalServerError)
+		return
+	}
+
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
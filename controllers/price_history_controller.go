@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+// ScheduledPriceRequest schedules a price change. Currency defaults to the
+// product's currency.
+type ScheduledPriceRequest struct {
+	Price    decimal.Decimal `json:"price"`
+	Currency string          `json:"currency"`
+	ApplyAt  time.Time       `json:"apply_at"`
+}
+
+func (h *Handler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := models.GetPriceHistory(h.DB, id, limit)
+	if err != nil {
+		http.Error(w, "Failed to get price history", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+func (h *Handler) GetScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	scheduled, err := models.GetScheduledPrices(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get scheduled prices", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (h *Handler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req ScheduledPriceRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	scheduled := models.ScheduledPrice{
+		ProductID: id,
+		Price:     models.Price{Amount: req.Price, Currency: req.Currency},
+		ApplyAt:   req.ApplyAt,
+		CreatedBy: actorID,
+	}
+	if scheduled.Price.Currency == "" {
+		scheduled.Price.Currency = product.Currency
+	}
+	err = scheduled.Price.Validate()
+	if err != nil {
+		http.Error(w, "Invalid price or currency", http.StatusBadRequest)
+		return
+	}
+
+	err = scheduled.Create(h.DB)
+	if errors.Is(err, models.ErrScheduledPriceInPast) {
+		http.Error(w, "apply_at must be in the future", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to schedule price", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (h *Handler) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	scheduledID, err := strconv.Atoi(vars["scheduled_id"])
+	if err != nil {
+		http.Error(w, "Invalid scheduled price ID", http.StatusBadRequest)
+		return
+	}
+
+	_, err = models.CancelScheduledPrice(h.DB, id, scheduledID)
+	if errors.Is(err, models.ErrScheduledPriceNotFound) {
+		http.Error(w, "Scheduled price not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrScheduledPriceNotPending) {
+		http.Error(w, "Scheduled price is no longer pending", http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to cancel scheduled price", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -10,31 +11,89 @@ import (
 	"github.com/yourusername/yourproject/services"
 )
 
-func CreateProduct(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateProduct(w http.ResponseWriter, r *http.Request) {
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var product models.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
+	err = json.NewDecoder(r.Body).Decode(&product)
 	if err != nil {
 		http.Error(w, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
 
-	err = product.Create(services.DB)
-	if err != nil {
-		http.Error(w, "Failed to create product", http.StatusInternalServerError)
+	// Variants and prices in other currencies have their own endpoints
+	product.Variants = nil
+	product.Prices = nil
+
+	// Products start as drafts unless published right away. Later status
+	// changes have their own endpoints.
+	switch product.Status {
+	case "":
+		product.Status = models.ProductDraft
+	case models.ProductDraft, models.ProductPublished:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
 		return
 	}
+	product.PublishAt = nil
 
-	err = services.Queue.AddProductImages(product.ProductImages)
+	err = product.Validate()
+	if errors.Is(err, models.ErrInvalidSearchLanguage) {
+		http.Error(w, "Invalid search language", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Invalid price or currency", http.StatusBadRequest)
+		return
+	}
+
+	err = product.Create(h.DB, actorID)
 	if err != nil {
-		http.Error(w, "Failed to add product images to queue", http.StatusInternalServerError)
+		http.Error(w, "Failed to create product", http.StatusInternalServerError)
 		return
 	}
 
+	// Also drops a cached "not found" left by earlier lookups of this ID
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	if h.Queue != nil {
+		err = h.Queue.AddProductImages(product.ProductImages)
+		if err != nil {
+			http.Error(w, "Failed to add product images to queue", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(product)
 }
 
-func GetProductByID(w http.ResponseWriter, r *http.Request) {
+// loadVisibleProduct loads a product through the cache if the viewer, as
+// given by X-User-ID, may see it: unpublished products are only shown to
+// their owner. Otherwise it responds with an error and returns false.
+func (h *Handler) loadVisibleProduct(w http.ResponseWriter, r *http.Request, id int) (*models.Product, bool) {
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	product, err := services.LoadProductByID(h.Cache, id, func() (models.Product, error) {
+		var product models.Product
+		err := product.GetByID(h.DB, id)
+		return product, err
+	})
+	if err != nil || product.Status != models.ProductPublished && actorID != product.UserID {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return nil, false
+	}
+	return product, true
+}
+
+func (h *Handler) GetProductByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -42,40 +101,267 @@ func GetProductByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err := services.Cache.GetProductByID(id)
-	if err == nil {
-		json.NewEncoder(w).Encode(product)
+	currency, rounding, err := parseCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var product models.Product
-	err = product.GetByID(services.DB, id)
-	if err != nil {
-		http.Error(w, "Product not found", http.StatusNotFound)
+	cached, ok := h.loadVisibleProduct(w, r, id)
+	if !ok {
 		return
 	}
 
-	services.Cache.SetProductByID(id, product)
+	// Work on a copy, the cache may share the product with other requests.
+	// Promotions are applied after the cache as they start and end on their
+	// own.
+	product := *cached
+	err = models.ApplyPromotions(h.DB, []*models.Product{&product})
+	if err != nil {
+		http.Error(w, "Failed to get promotions", http.StatusInternalServerError)
+		return
+	}
+	if !h.convertProducts(w, []*models.Product{&product}, currency, rounding) {
+		return
+	}
 
-	json.NewEncoder(w).Encode(product)
+	writeProduct(w, r, product)
 }
 
-func GetAllProducts(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetAllProducts(w http.ResponseWriter, r *http.Request) {
 	userID, err := strconv.Atoi(r.URL.Query().Get("user_id"))
 	if err != nil {
 		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	minPrice, _ := strconv.ParseFloat(r.URL.Query().Get("min_price"), 64)
-	maxPrice, _ := strconv.ParseFloat(r.URL.Query().Get("max_price"), 64)
-	productName := r.URL.Query().Get("product_name")
+	filter, err := parseProductFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.UserID = userID
+
+	owner, err := scopeToViewer(r, &filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePageOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	products, err := models.GetAllProducts(services.DB, userID, minPrice, maxPrice, productName)
+	currency, rounding, err := parseCurrency(r)
 	if err != nil {
-		http.Error(w, "Failed to get products", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Prices are converted after the cache, so that every currency shares
+	// a page and pages don't go stale when exchange rates change. Owners and
+	// other viewers see different products for the same query.
+	query := r.URL.Query()
+	query.Del("currency")
+	query.Del("rounding")
+	cacheKey := services.ProductListKey(query)
+	if owner {
+		cacheKey = "owner:" + cacheKey
+	}
+	products, err := h.Cache.GetProductList(userID, cacheKey)
+	if err != nil {
+		products, err = models.GetAllProducts(h.DB, filter, page)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			http.Error(w, "Failed to get products", http.StatusInternalServerError)
+			return
+		}
+		h.Cache.SetProductList(userID, cacheKey, products)
+	}
+
+	if currency != "" {
+		// The in-process cache hands out the cached page itself
+		result := *products
+		result.Products = append([]models.Product(nil), products.Products...)
+		converted := make([]*models.Product, len(result.Products))
+		for i := range result.Products {
+			converted[i] = &result.Products[i]
+		}
+		if !h.convertProducts(w, converted, currency, rounding) {
+			return
+		}
+		products = &result
+	}
+
 	json.NewEncoder(w).Encode(products)
 }
+
+func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var existing models.Product
+	err = existing.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if actorID != existing.UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkIfMatch(w, r, existing.Version) {
+		return
+	}
+
+	var product models.Product
+	err = json.NewDecoder(r.Body).Decode(&product)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	product.ID = id
+	product.Version = existing.Version
+
+	h.saveProduct(w, r, existing, product, actorID)
+}
+
+// PatchProduct changes only the fields present in the request body.
+func (h *Handler) PatchProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var existing models.Product
+	err = existing.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if actorID != existing.UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkIfMatch(w, r, existing.Version) {
+		return
+	}
+
+	// Decoding over a copy keeps the fields the body leaves out
+	product := existing
+	err = json.NewDecoder(r.Body).Decode(&product)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	product.ID = id
+	product.Version = existing.Version
+
+	h.saveProduct(w, r, existing, product, actorID)
+}
+
+// saveProduct updates existing with the fields of product, for PUT and
+// PATCH requests, and writes the result.
+func (h *Handler) saveProduct(w http.ResponseWriter, r *http.Request, existing, product models.Product, actorID int) {
+	err := product.Validate()
+	if errors.Is(err, models.ErrInvalidSearchLanguage) {
+		http.Error(w, "Invalid search language", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Invalid price or currency", http.StatusBadRequest)
+		return
+	}
+
+	err = product.Update(h.DB, actorID)
+	if errors.Is(err, models.ErrVersionConflict) {
+		http.Error(w, "Product was modified", http.StatusPreconditionFailed)
+		return
+	} else if errors.Is(err, models.ErrProductNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to update product", http.StatusInternalServerError)
+		return
+	}
+
+	// Pick up the variants and prices, which may have been pruned
+	err = product.GetByID(h.DB, product.ID)
+	if err != nil {
+		http.Error(w, "Failed to get product", http.StatusInternalServerError)
+		return
+	}
+
+	services.SyncProductCache(h.Cache, h.Invalidations, product)
+	if existing.UserID != product.UserID {
+		services.InvalidateUserProductLists(h.Cache, h.Invalidations, existing.UserID)
+	}
+
+	writeProduct(w, r, product)
+}
+
+func (h *Handler) DeleteProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if actorID != product.UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkIfMatch(w, r, product.Version) {
+		return
+	}
+
+	err = product.Delete(h.DB)
+	if errors.Is(err, models.ErrProductNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrVersionConflict) {
+		http.Error(w, "Product was modified", http.StatusPreconditionFailed)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
+		return
+	}
+
+	services.InvalidateProduct(h.Cache, h.Invalidations, id, product.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
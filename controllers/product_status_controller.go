@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// PublishRequest is the optional body of POST /products/{id}/publish. With
+// PublishAt set, the product stays a draft until then.
+type PublishRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func (h *Handler) PublishProduct(w http.ResponseWriter, r *http.Request) {
+	var req PublishRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	h.setProductStatus(w, r, models.ProductPublished, req.PublishAt)
+}
+
+// UnpublishProduct moves a published or archived product back to draft, or
+// cancels the scheduled publication of a draft.
+func (h *Handler) UnpublishProduct(w http.ResponseWriter, r *http.Request) {
+	h.setProductStatus(w, r, models.ProductDraft, nil)
+}
+
+func (h *Handler) ArchiveProduct(w http.ResponseWriter, r *http.Request) {
+	h.setProductStatus(w, r, models.ProductArchived, nil)
+}
+
+func (h *Handler) setProductStatus(w http.ResponseWriter, r *http.Request, status string, publishAt *time.Time) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product := models.Product{ID: id}
+	err = product.SetStatus(h.DB, status, publishAt, actorID)
+	if errors.Is(err, models.ErrProductNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrNotProductOwner) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	} else if errors.Is(err, models.ErrInvalidTransition) {
+		http.Error(w, "Product can't be moved to "+status+" from its current status", http.StatusConflict)
+		return
+	} else if errors.Is(err, models.ErrPublishAtInPast) {
+		http.Error(w, "publish_at must be in the future", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to set product status", http.StatusInternalServerError)
+		return
+	}
+
+	// The product appears in or disappears from public listings
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(product)
+}
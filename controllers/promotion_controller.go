@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// GetPromotions lists every promotion, or with active=true only those
+// running now.
+func (h *Handler) GetPromotions(w http.ResponseWriter, r *http.Request) {
+	promotions, err := models.GetPromotions(h.DB, r.URL.Query().Get("active") == "true")
+	if err != nil {
+		http.Error(w, "Failed to get promotions", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(promotions)
+}
+
+func (h *Handler) GetPromotionByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+
+	var promotion models.Promotion
+	err = promotion.GetByID(h.DB, id)
+	if err != nil {
+		writePromotionError(w, err, "Failed to get promotion")
+		return
+	}
+
+	json.NewEncoder(w).Encode(promotion)
+}
+
+func (h *Handler) CreatePromotion(w http.ResponseWriter, r *http.Request) {
+	var promotion models.Promotion
+	err := json.NewDecoder(r.Body).Decode(&promotion)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	err = promotion.Validate()
+	if err != nil {
+		writePromotionError(w, err, "Invalid promotion")
+		return
+	}
+
+	err = promotion.Create(h.DB)
+	if err != nil {
+		writePromotionError(w, err, "Failed to create promotion")
+		return
+	}
+	if !h.invalidatePromotedListings(w, promotion, nil) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(promotion)
+}
+
+func (h *Handler) UpdatePromotion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+
+	var promotion models.Promotion
+	err = json.NewDecoder(r.Body).Decode(&promotion)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	promotion.ID = id
+
+	err = promotion.Validate()
+	if err != nil {
+		writePromotionError(w, err, "Invalid promotion")
+		return
+	}
+
+	// The products it no longer applies to change too
+	owners, err := promotion.GetOwners(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to update promotion", http.StatusInternalServerError)
+		return
+	}
+
+	err = promotion.Update(h.DB)
+	if err != nil {
+		writePromotionError(w, err, "Failed to update promotion")
+		return
+	}
+	if !h.invalidatePromotedListings(w, promotion, owners) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(promotion)
+}
+
+func (h *Handler) DeletePromotion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+
+	promotion := models.Promotion{ID: id}
+	owners, err := promotion.GetOwners(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to delete promotion", http.StatusInternalServerError)
+		return
+	}
+
+	err = promotion.Delete(h.DB)
+	if err != nil {
+		writePromotionError(w, err, "Failed to delete promotion")
+		return
+	}
+	for _, userID := range owners {
+		services.InvalidateUserProductLists(h.Cache, h.Invalidations, userID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// invalidatePromotedListings evicts the cached listings of the owners of the
+// products the promotion applies to after a change, and of the owners it
+// applied to before. It responds with an error and returns false if the
+// owners can't be found.
+func (h *Handler) invalidatePromotedListings(w http.ResponseWriter, promotion models.Promotion, owners []int) bool {
+	current, err := promotion.GetOwners(h.DB)
+	if err != nil {
+		http.Error(w, "Failed to get promoted products", http.StatusInternalServerError)
+		return false
+	}
+
+	for _, userID := range append(owners, current...) {
+		services.InvalidateUserProductLists(h.Cache, h.Invalidations, userID)
+	}
+	return true
+}
+
+// writePromotionError maps promotion errors to a status code, falling back
+// to a 500 with msg.
+func writePromotionError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrPromotionNotFound):
+		http.Error(w, "Promotion not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidPromotion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+func (h *Handler) GetProductRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	revisions, err := models.GetProductRevisions(h.DB, id, limit)
+	if err != nil {
+		http.Error(w, "Failed to get revisions", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+func (h *Handler) GetProductRevision(w http.ResponseWriter, r *http.Request) {
+	id, revision, ok := parseRevisionPath(w, r)
+	if !ok {
+		return
+	}
+
+	rev, err := models.GetProductRevision(h.DB, id, revision)
+	if errors.Is(err, models.ErrRevisionNotFound) {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get revision", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(rev)
+}
+
+// DiffProductRevisions compares the revisions given by the from and to
+// parameters.
+func (h *Handler) DiffProductRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from revision", http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to revision", http.StatusBadRequest)
+		return
+	}
+
+	diff, err := models.DiffRevisions(h.DB, id, from, to)
+	if errors.Is(err, models.ErrRevisionNotFound) {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to compare revisions", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(diff)
+}
+
+func (h *Handler) RevertProduct(w http.ResponseWriter, r *http.Request) {
+	id, revision, ok := parseRevisionPath(w, r)
+	if !ok {
+		return
+	}
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+	if actorID != product.UserID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkIfMatch(w, r, product.Version) {
+		return
+	}
+	previousOwner := product.UserID
+
+	err = product.Revert(h.DB, revision, actorID)
+	if errors.Is(err, models.ErrRevisionNotFound) {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrVersionConflict) {
+		http.Error(w, "Product was modified", http.StatusPreconditionFailed)
+		return
+	} else if errors.Is(err, models.ErrProductNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to revert product", http.StatusInternalServerError)
+		return
+	}
+
+	// Pick up the variants and prices, which may have been pruned
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product", http.StatusInternalServerError)
+		return
+	}
+
+	services.SyncProductCache(h.Cache, h.Invalidations, product)
+	if previousOwner != product.UserID {
+		services.InvalidateUserProductLists(h.Cache, h.Invalidations, previousOwner)
+	}
+
+	writeProduct(w, r, product)
+}
+
+// parseRevisionPath reads the product ID and revision number from the URL,
+// writing a 400 response if either is invalid.
+func parseRevisionPath(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return id, revision, true
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+func (h *Handler) SearchProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter, err := parseProductFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("user_id"); v != "" {
+		filter.UserID, err = strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, err = scopeToViewer(r, &filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := models.SearchOptions{
+		Query:    query.Get("q"),
+		Language: query.Get("lang"),
+		Prefix:   query.Get("prefix") == "true",
+		Filter:   filter,
+	}
+	if v := query.Get("limit"); v != "" {
+		opts.Limit, err = strconv.Atoi(v)
+		if err != nil || opts.Limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		opts.Offset, err = strconv.Atoi(v)
+		if err != nil || opts.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+	opts.Facets, err = parseFacetOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	currency, rounding, err := parseCurrency(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := models.SearchProducts(h.DB, opts)
+	if errors.Is(err, models.ErrEmptySearchQuery) {
+		http.Error(w, "Missing search query", http.StatusBadRequest)
+		return
+	} else if errors.Is(err, models.ErrInvalidSearchLanguage) {
+		http.Error(w, "Invalid search language", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to search products", http.StatusInternalServerError)
+		return
+	}
+
+	products := make([]*models.Product, len(page.Results))
+	for i := range page.Results {
+		products[i] = &page.Results[i].Product
+	}
+	if !h.convertProducts(w, products, currency, rounding) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
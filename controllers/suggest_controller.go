@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+// SuggestResponse is returned by GET /products/suggest.
+type SuggestResponse struct {
+	Suggestions []string `json:"suggestions"`
+}
+
+func (h *Handler) SuggestProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	userID, err := strconv.Atoi(query.Get("user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	prefix := strings.TrimSpace(query.Get("prefix"))
+	if prefix == "" {
+		http.Error(w, "Missing prefix", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only owners are reminded of their unpublished products
+	suggestions, err := models.SuggestProductNames(h.DB, userID, prefix, limit, actorID != userID)
+	if err != nil {
+		http.Error(w, "Failed to suggest products", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(SuggestResponse{Suggestions: suggestions})
+}
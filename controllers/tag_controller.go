@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// ProductTagsRequest lists tags to add to a product.
+type ProductTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func (h *Handler) GetProductTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	_, ok := h.loadVisibleProduct(w, r, id)
+	if !ok {
+		return
+	}
+
+	tags, err := models.GetProductTags(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product tags", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *Handler) AddProductTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ProductTagsRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	tags, err := models.NormalizeTags(req.Tags)
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.AddProductTags(h.DB, id, tags)
+	if err != nil {
+		http.Error(w, "Failed to add product tags", http.StatusInternalServerError)
+		return
+	}
+	services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+
+	tags, err = models.GetProductTags(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product tags", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *Handler) RemoveProductTag(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	tag, err := models.NormalizeTag(vars["tag"])
+	if err != nil {
+		http.Error(w, "Invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	err = models.RemoveProductTag(h.DB, id, tag)
+	if err != nil {
+		http.Error(w, "Failed to remove product tag", http.StatusInternalServerError)
+		return
+	}
+	services.InvalidateUserProductLists(h.Cache, h.Invalidations, product.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetUserTags(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Only the owner's tag counts include unpublished products
+	status := ""
+	if actorID != userID {
+		status = models.ProductPublished
+	}
+
+	tags, err := models.GetUserTags(h.DB, userID, status)
+	if err != nil {
+		http.Error(w, "Failed to get user tags", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tags)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+// GetUserTrash lists the deleted products of a user. Only the user, as
+// given by X-User-ID, may see them.
+func (h *Handler) GetUserTrash(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if actorID != userID {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var limit int
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	products, err := models.GetDeletedProducts(h.DB, userID, limit)
+	if err != nil {
+		http.Error(w, "Failed to get deleted products", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(products)
+}
+
+// RestoreProduct takes a product out of the trash. Like GetUserTrash, only
+// its owner, as given by X-User-ID, may do so.
+func (h *Handler) RestoreProduct(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	actorID, err := parseActor(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	product := models.Product{ID: id}
+	err = product.Restore(h.DB, actorID)
+	if errors.Is(err, models.ErrProductNotFound) {
+		http.Error(w, "Product not in trash", http.StatusNotFound)
+		return
+	} else if errors.Is(err, models.ErrNotProductOwner) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to restore product", http.StatusInternalServerError)
+		return
+	}
+
+	// Also drops the cached "not found" left by lookups while it was deleted
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get product", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(product)
+}
@@ -7,10 +7,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/yourusername/yourproject/models"
-	"github.com/yourusername/yourproject/services"
 )
 
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	err := json.NewDecoder(r.Body).Decode(&user)
 	if err != nil {
@@ -18,7 +17,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = user.CreateUser(services.DB)
+	err = user.CreateUser(h.DB)
 	if err != nil {
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
@@ -28,7 +27,7 @@ func CreateUser(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(user)
 }
 
-func GetUserByID(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
@@ -37,7 +36,7 @@ func GetUserByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user models.User
-	err = user.GetUserByID(services.DB, id)
+	err = user.GetUserByID(h.DB, id)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
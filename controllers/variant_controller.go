@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+func (h *Handler) GetProductVariants(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	_, ok := h.loadVisibleProduct(w, r, id)
+	if !ok {
+		return
+	}
+
+	variants, err := models.GetProductVariants(h.DB, id)
+	if err != nil {
+		http.Error(w, "Failed to get variants", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(variants)
+}
+
+func (h *Handler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	var variant models.Variant
+	err = json.NewDecoder(r.Body).Decode(&variant)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	variant.ProductID = id
+
+	err = variant.Validate(product)
+	if err == nil {
+		err = variant.Create(h.DB)
+	}
+	if err != nil {
+		writeVariantError(w, err, "Failed to create variant")
+		return
+	}
+
+	// The product is cached with its variants
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *Handler) GetVariantByID(w http.ResponseWriter, r *http.Request) {
+	id, variantID, ok := parseVariantPath(w, r)
+	if !ok {
+		return
+	}
+
+	var variant models.Variant
+	err := variant.GetByID(h.DB, id, variantID)
+	if err != nil {
+		writeVariantError(w, err, "Failed to get variant")
+		return
+	}
+
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *Handler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	id, variantID, ok := parseVariantPath(w, r)
+	if !ok {
+		return
+	}
+
+	var product models.Product
+	err := product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	var variant models.Variant
+	err = json.NewDecoder(r.Body).Decode(&variant)
+	if err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	variant.ID = variantID
+	variant.ProductID = id
+
+	err = variant.Validate(product)
+	if err == nil {
+		err = variant.Update(h.DB)
+	}
+	if err != nil {
+		writeVariantError(w, err, "Failed to update variant")
+		return
+	}
+
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	json.NewEncoder(w).Encode(variant)
+}
+
+func (h *Handler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	id, variantID, ok := parseVariantPath(w, r)
+	if !ok {
+		return
+	}
+
+	var product models.Product
+	err := product.GetByID(h.DB, id)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	variant := models.Variant{ID: variantID, ProductID: id}
+	err = variant.Delete(h.DB)
+	if err != nil {
+		writeVariantError(w, err, "Failed to delete variant")
+		return
+	}
+
+	services.InvalidateProduct(h.Cache, h.Invalidations, product.ID, product.UserID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseVariantPath reads the product and variant IDs of
+// /products/{id}/variants/{variant_id}, responding with 400 if either is
+// invalid.
+func parseVariantPath(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	variantID, err := strconv.Atoi(vars["variant_id"])
+	if err != nil {
+		http.Error(w, "Invalid variant ID", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return id, variantID, true
+}
+
+// writeVariantError responds with the status matching a variant error, or
+// 500 with msg.
+func writeVariantError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, models.ErrVariantNotFound):
+		http.Error(w, "Variant not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidSKU):
+		http.Error(w, "Invalid SKU", http.StatusBadRequest)
+	case errors.Is(err, models.ErrInvalidVariantPrice):
+		http.Error(w, "Invalid price override", http.StatusBadRequest)
+	case errors.Is(err, models.ErrInvalidVariantImage):
+		http.Error(w, "Variant images must be images of the product", http.StatusBadRequest)
+	case errors.Is(err, models.ErrDuplicateSKU):
+		http.Error(w, "SKU already exists", http.StatusConflict)
+	case errors.Is(err, models.ErrDuplicateVariantOption):
+		http.Error(w, "Variant with these options already exists", http.StatusConflict)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
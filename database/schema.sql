@@ -1,3 +1,6 @@
+-- Trigram matching for GET /products/suggest
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 CREATE TABLE users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(255) NOT NULL,
@@ -11,6 +14,205 @@ CREATE TABLE products (
     product_name VARCHAR(255) NOT NULL,
     product_description TEXT,
     product_images TEXT[],
-    product_price DECIMAL(10, 2),
-    compressed_product_images TEXT[]
+    -- Exact amount in currency, with at most as many decimal places as the
+    -- currency allows
+    product_price NUMERIC(15, 3),
+    currency CHAR(3) NOT NULL DEFAULT 'USD',
+    compressed_product_images TEXT[],
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- draft, published or archived; only published products are public
+    status VARCHAR(16) NOT NULL DEFAULT 'draft',
+    -- When a draft is scheduled to be published
+    publish_at TIMESTAMPTZ,
+    -- Incremented by every change of the row; the ETag of GET /products/:id
+    version INT NOT NULL DEFAULT 1,
+    -- When the product was moved to the trash; it is purged once
+    -- TRASH_RETENTION_DAYS have passed
+    deleted_at TIMESTAMPTZ,
+    -- Text search configuration used to index the product; see SEARCH_LANGUAGE
+    search_language REGCONFIG NOT NULL DEFAULT 'english',
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector(search_language, COALESCE(product_name, '')), 'A') ||
+        setweight(to_tsvector(search_language, COALESCE(product_description, '')), 'B')
+    ) STORED
+);
+
+-- Keyset pagination indexes for each sort order of GET /products
+CREATE INDEX idx_products_user_created_at ON products (user_id, created_at, id);
+CREATE INDEX idx_products_user_price ON products (user_id, product_price, id);
+CREATE INDEX idx_products_user_name ON products (user_id, product_name, id);
+
+-- Drafts due to be published by the publish scheduler
+CREATE INDEX idx_products_publish_at ON products (publish_at) WHERE status = 'draft' AND publish_at IS NOT NULL;
+
+-- Trash listings and the trash purger
+CREATE INDEX idx_products_deleted_at ON products (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Full-text search for GET /products/search
+CREATE INDEX idx_products_search ON products USING GIN (search_vector);
+
+-- Name completion for GET /products/suggest
+CREATE INDEX idx_products_name_trgm ON products USING GIN (product_name gin_trgm_ops);
+
+-- Product taxonomy. A category can only be deleted once it has no
+-- subcategories.
+CREATE TABLE categories (
+    id SERIAL PRIMARY KEY,
+    parent_id INT REFERENCES categories(id),
+    name VARCHAR(255) NOT NULL
+);
+
+-- Sibling categories have distinct names
+CREATE UNIQUE INDEX idx_categories_parent_name ON categories (COALESCE(parent_id, 0), lower(name));
+
+CREATE TABLE product_categories (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX idx_product_categories_category ON product_categories (category_id, product_id);
+
+-- Free-form labels such as "clearance", stored normalized to lower case
+CREATE TABLE product_tags (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    tag VARCHAR(64) NOT NULL,
+    PRIMARY KEY (product_id, tag)
+);
+
+CREATE INDEX idx_product_tags_tag ON product_tags (tag, product_id);
+
+-- Purchasable versions of a product, e.g. sizes and colors. A variant
+-- without a price_override costs the product's price, and its images are a
+-- subset of the product's images.
+CREATE TABLE product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku VARCHAR(64) NOT NULL,
+    options JSONB NOT NULL DEFAULT '{}',
+    price_override NUMERIC(15, 3),
+    images TEXT[] NOT NULL DEFAULT '{}',
+    CONSTRAINT product_variants_sku_key UNIQUE (sku),
+    CONSTRAINT product_variants_product_id_options_key UNIQUE (product_id, options)
+);
+
+-- Stock of a product, or of one of its variants. Reserved units are held by
+-- pending reservations.
+CREATE TABLE inventory (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+    on_hand INT NOT NULL DEFAULT 0,
+    reserved INT NOT NULL DEFAULT 0,
+    low_stock_threshold INT NOT NULL DEFAULT 0,
+    CHECK (reserved >= 0 AND reserved <= on_hand)
+);
+
+CREATE UNIQUE INDEX idx_inventory_item ON inventory (product_id, COALESCE(variant_id, 0));
+
+CREATE TABLE inventory_reservations (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    status VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Ledger of every change to on_hand and reserved
+CREATE TABLE inventory_adjustments (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE,
+    on_hand_delta INT NOT NULL,
+    reserved_delta INT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    note TEXT,
+    reservation_id INT REFERENCES inventory_reservations(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_inventory_adjustments_product ON inventory_adjustments (product_id, id);
+
+-- Prices of a product in currencies other than products.currency
+CREATE TABLE product_prices (
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    currency CHAR(3) NOT NULL,
+    amount NUMERIC(15, 3) NOT NULL CHECK (amount >= 0),
+    PRIMARY KEY (product_id, currency)
+);
+
+-- One unit of base costs rate units of quote. Loaded through
+-- PUT /admin/exchange-rates.
+CREATE TABLE exchange_rates (
+    base CHAR(3) NOT NULL,
+    quote CHAR(3) NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (base, quote)
+);
+
+-- Every change of a product's price. changed_by is the X-User-ID of whoever
+-- made the change, or scheduled it; it isn't authenticated, so it has no
+-- foreign key.
+CREATE TABLE price_history (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    old_price NUMERIC(15, 3) NOT NULL,
+    old_currency CHAR(3) NOT NULL,
+    new_price NUMERIC(15, 3) NOT NULL,
+    new_currency CHAR(3) NOT NULL,
+    changed_by INT,
+    source VARCHAR(16) NOT NULL,
+    scheduled_change_id INT,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_price_history_product ON price_history (product_id, changed_at);
+
+-- Price changes applied at apply_at by the price scheduler
+CREATE TABLE scheduled_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price NUMERIC(15, 3) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    apply_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    created_by INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    applied_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_scheduled_prices_due ON scheduled_prices (apply_at) WHERE status = 'pending';
+
+-- Discounts on the products listed in product_ids, in any of category_ids
+-- or their descendants, or carrying any of tags. currency is only set for
+-- fixed discounts.
+CREATE TABLE promotions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    discount_type VARCHAR(16) NOT NULL,
+    amount NUMERIC(15, 3) NOT NULL,
+    currency CHAR(3),
+    starts_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ends_at TIMESTAMPTZ,
+    product_ids INT[] NOT NULL DEFAULT '{}',
+    category_ids INT[] NOT NULL DEFAULT '{}',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_promotions_window ON promotions (starts_at, ends_at);
+
+-- Numbered snapshots of a product's editable fields, saved on every create
+-- and update. changed_by is the unauthenticated X-User-ID, so it has no
+-- foreign key.
+CREATE TABLE product_revisions (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    revision INT NOT NULL,
+    snapshot JSONB NOT NULL,
+    changed_by INT,
+    reverted_from INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, revision)
 );
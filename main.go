@@ -1,34 +1,181 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/subtle"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 	"github.com/sirupsen/logrus"
+	"github.com/yourusername/yourproject/config"
+	"github.com/yourusername/yourproject/controllers"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/queue"
+	"github.com/yourusername/yourproject/services"
 )
 
 func main() {
-	// Load environment variables
-	err := godotenv.Load()
+	// Load configuration from the environment
+	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Fatalf("Error loading config: %v", err)
 	}
 
 	// Initialize logger
 	logger := logrus.New()
 	logger.Out = os.Stdout
+	services.InitLogger()
+
+	// Initialize storage
+	services.InitDB(fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName))
+
+	switch cfg.CacheDriver {
+	case "memory":
+		services.InitMemoryCache(services.DefaultMemoryCacheSize, services.DefaultCacheTTL)
+	case "tiered":
+		services.InitTieredCache(cfg.RedisHost, cfg.RedisPort)
+	default:
+		services.InitCache(cfg.RedisHost, cfg.RedisPort)
+	}
+	services.InstrumentCache()
+	if cfg.CacheWriteStrategy != "" {
+		services.CacheWriteStrategy = cfg.CacheWriteStrategy
+	}
+
+	// Evict products changed by other instances and the image processor
+	if cfg.RedisHost != "" {
+		services.InitInvalidationBus(cfg.RedisHost, cfg.RedisPort)
+		go services.ListenForInvalidations(services.Invalidations, services.Cache)
+	}
+
+	// Apply scheduled price changes in the background
+	priceScheduler := services.NewPriceScheduler(services.DB, services.Cache, logger)
+	priceScheduler.Invalidations = services.Invalidations
+	go priceScheduler.Run()
+
+	// Publish drafts whose scheduled publish time has passed
+	publishScheduler := services.NewPublishScheduler(services.DB, services.Cache, logger)
+	publishScheduler.Invalidations = services.Invalidations
+	go publishScheduler.Run()
+
+	// Purge products that have been in the trash past their retention
+	var s3Client *s3.S3
+	if cfg.S3Bucket != "" {
+		s3Client = s3.New(session.Must(session.NewSession()))
+	}
+	trashPurger := services.NewTrashPurger(services.DB, s3Client, cfg.S3Bucket, logger)
+	if cfg.TrashRetentionDays != "" {
+		days, err := strconv.Atoi(cfg.TrashRetentionDays)
+		if err != nil || days < 1 {
+			log.Fatalf("Invalid TRASH_RETENTION_DAYS: %q", cfg.TrashRetentionDays)
+		}
+		trashPurger.Retention = time.Duration(days) * 24 * time.Hour
+	}
+	go trashPurger.Run()
+
+	if cfg.SearchLanguage != "" {
+		models.DefaultSearchLanguage = cfg.SearchLanguage
+	}
+
+	// New product images are compressed by the image processor
+	imageQueue, err := queue.NewQueue()
+	if err != nil {
+		log.Fatalf("Error connecting to queue: %v", err)
+	}
+	defer imageQueue.Close()
+
+	if s3Client != nil {
+		imageChannel, err := imageQueue.NewChannel()
+		if err != nil {
+			log.Fatalf("Error opening image queue channel: %v", err)
+		}
+		imageProcessor := services.NewImageProcessor(services.DB, s3Client, imageChannel, services.Cache, logger)
+		imageProcessor.Invalidations = services.Invalidations
+		go imageProcessor.ProcessImages()
+	} else {
+		logger.Warn("S3_BUCKET is not set, images won't be compressed")
+	}
+
+	handler := controllers.NewHandler(services.DB, services.Cache, imageQueue)
+	handler.Invalidations = services.Invalidations
 
 	// Set up router
 	router := mux.NewRouter()
 
 	// Define routes
-	router.HandleFunc("/products", createProduct).Methods("POST")
-	router.HandleFunc("/products/{id}", getProductByID).Methods("GET")
-	router.HandleFunc("/products", getAllProducts).Methods("GET")
+	router.HandleFunc("/products", handler.CreateProduct).Methods("POST")
+	router.HandleFunc("/products/search", handler.SearchProducts).Methods("GET")
+	router.HandleFunc("/products/suggest", handler.SuggestProducts).Methods("GET")
+	router.HandleFunc("/products/{id}", handler.GetProductByID).Methods("GET")
+	router.HandleFunc("/products", handler.GetAllProducts).Methods("GET")
+	router.HandleFunc("/products/{id}", handler.UpdateProduct).Methods("PUT")
+	router.HandleFunc("/products/{id}", handler.PatchProduct).Methods("PATCH")
+	router.HandleFunc("/products/{id}", handler.DeleteProduct).Methods("DELETE")
+	router.HandleFunc("/products/{id}/publish", handler.PublishProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/unpublish", handler.UnpublishProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/archive", handler.ArchiveProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/restore", handler.RestoreProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/revisions", handler.GetProductRevisions).Methods("GET")
+	router.HandleFunc("/products/{id}/revisions/diff", handler.DiffProductRevisions).Methods("GET")
+	router.HandleFunc("/products/{id}/revisions/{revision}", handler.GetProductRevision).Methods("GET")
+	router.HandleFunc("/products/{id}/revisions/{revision}/revert", handler.RevertProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/categories", handler.GetProductCategories).Methods("GET")
+	router.HandleFunc("/products/{id}/categories", handler.SetProductCategories).Methods("PUT")
+	router.HandleFunc("/products/{id}/variants", handler.GetProductVariants).Methods("GET")
+	router.HandleFunc("/products/{id}/variants", handler.CreateVariant).Methods("POST")
+	router.HandleFunc("/products/{id}/variants/{variant_id}", handler.GetVariantByID).Methods("GET")
+	router.HandleFunc("/products/{id}/variants/{variant_id}", handler.UpdateVariant).Methods("PUT")
+	router.HandleFunc("/products/{id}/variants/{variant_id}", handler.DeleteVariant).Methods("DELETE")
+	router.HandleFunc("/products/{id}/prices", handler.GetProductPrices).Methods("GET")
+	router.HandleFunc("/products/{id}/prices/{currency}", handler.SetProductPrice).Methods("PUT")
+	router.HandleFunc("/products/{id}/prices/{currency}", handler.DeleteProductPrice).Methods("DELETE")
+	router.HandleFunc("/products/{id}/price-history", handler.GetPriceHistory).Methods("GET")
+	router.HandleFunc("/products/{id}/scheduled-prices", handler.GetScheduledPrices).Methods("GET")
+	router.HandleFunc("/products/{id}/scheduled-prices", handler.SchedulePrice).Methods("POST")
+	router.HandleFunc("/products/{id}/scheduled-prices/{scheduled_id}", handler.CancelScheduledPrice).Methods("DELETE")
+	router.HandleFunc("/products/{id}/inventory", handler.GetProductStock).Methods("GET")
+	router.HandleFunc("/products/{id}/inventory", handler.SetLowStockThreshold).Methods("PUT")
+	router.HandleFunc("/products/{id}/inventory/adjustments", handler.GetStockAdjustments).Methods("GET")
+	router.HandleFunc("/products/{id}/inventory/adjustments", handler.AdjustStock).Methods("POST")
+	router.HandleFunc("/products/{id}/reservations", handler.ReserveStock).Methods("POST")
+	router.HandleFunc("/reservations/{id}/release", handler.ReleaseReservation).Methods("POST")
+	router.HandleFunc("/reservations/{id}/commit", handler.CommitReservation).Methods("POST")
+	router.HandleFunc("/products/{id}/tags", handler.GetProductTags).Methods("GET")
+	router.HandleFunc("/products/{id}/tags", handler.AddProductTags).Methods("POST")
+	router.HandleFunc("/products/{id}/tags/{tag}", handler.RemoveProductTag).Methods("DELETE")
+	router.HandleFunc("/exchange-rates", handler.GetExchangeRates).Methods("GET")
+	router.HandleFunc("/promotions", handler.GetPromotions).Methods("GET")
+	router.HandleFunc("/promotions/{id}", handler.GetPromotionByID).Methods("GET")
+	router.HandleFunc("/categories", handler.GetCategories).Methods("GET")
+	router.HandleFunc("/categories/{id}", handler.GetCategoryByID).Methods("GET")
+	router.HandleFunc("/users", handler.CreateUser).Methods("POST")
+	router.HandleFunc("/users/{id}", handler.GetUserByID).Methods("GET")
+	router.HandleFunc("/users/{id}/tags", handler.GetUserTags).Methods("GET")
+	router.HandleFunc("/users/{id}/trash", handler.GetUserTrash).Methods("GET")
+	router.HandleFunc("/metrics", handler.GetMetrics).Methods("GET")
+
+	// Admin routes
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(adminMiddleware(cfg.AdminToken))
+	admin.HandleFunc("/cache/products/{id}", handler.GetCachedProduct).Methods("GET")
+	admin.HandleFunc("/cache/products/{id}", handler.PurgeCachedProduct).Methods("DELETE")
+	admin.HandleFunc("/cache/users/{id}", handler.PurgeCachedUserProducts).Methods("DELETE")
+	admin.HandleFunc("/cache/warm", handler.WarmProductCache).Methods("POST")
+	admin.HandleFunc("/exchange-rates", handler.SetExchangeRates).Methods("PUT")
+	admin.HandleFunc("/images/backfill", handler.BackfillImages).Methods("POST")
+	admin.HandleFunc("/categories", handler.CreateCategory).Methods("POST")
+	admin.HandleFunc("/categories/{id}", handler.UpdateCategory).Methods("PUT")
+	admin.HandleFunc("/categories/{id}", handler.DeleteCategory).Methods("DELETE")
+	admin.HandleFunc("/promotions", handler.CreatePromotion).Methods("POST")
+	admin.HandleFunc("/promotions/{id}", handler.UpdatePromotion).Methods("PUT")
+	admin.HandleFunc("/promotions/{id}", handler.DeletePromotion).Methods("DELETE")
 
 	// Middleware for logging
 	router.Use(loggingMiddleware(logger))
@@ -49,57 +196,14 @@ func loggingMiddleware(logger *logrus.Logger) mux.MiddlewareFunc {
 	}
 }
 
-func createProduct(w http.ResponseWriter, r *http.Request) {
-	// Parse the request body
-	var product Product
-	err := json.NewDecoder(r.Body).Decode(&product)
-	if err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	// Save the product to the database (placeholder logic)
-	// In a real application, you would save the product to a database
-	product.ID = "12345" // Mock ID
-
-	// Respond with the created product
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(product)
-}
-
-func getProductByID(w http.ResponseWriter, r *http.Request) {
-	// Get the product ID from the URL
-	vars := mux.Vars(r)
-	productID := vars["id"]
-
-	// Retrieve the product from the database (placeholder logic)
-	// In a real application, you would retrieve the product from a database
-	product := Product{
-		ID:   productID,
-		Name: "Sample Product",
-	}
-
-	// Respond with the product
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(product)
-}
-
-func getAllProducts(w http.ResponseWriter, r *http.Request) {
-	// Retrieve all products from the database (placeholder logic)
-	// In a real application, you would retrieve the products from a database
-	products := []Product{
-		{ID: "12345", Name: "Sample Product 1"},
-		{ID: "67890", Name: "Sample Product 2"},
+func adminMiddleware(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Token")), []byte(token)) != 1 {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
 	}
-
-	// Respond with the list of products
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
-}
-
-// Product represents a product in the system
-type Product struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
 }
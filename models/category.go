@@ -0,0 +1,200 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes mapped to category errors.
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+var (
+	ErrCategoryNotFound    = errors.New("category not found")
+	ErrUnknownCategory     = errors.New("unknown parent or assigned category")
+	ErrCategoryExists      = errors.New("category already exists")
+	ErrCategoryCycle       = errors.New("category cannot be moved below itself")
+	ErrCategoryHasChildren = errors.New("category has subcategories")
+)
+
+// Category is a node of the product taxonomy. Top-level categories have no
+// ParentID.
+type Category struct {
+	ID       int    `json:"id"`
+	ParentID *int   `json:"parent_id"`
+	Name     string `json:"name"`
+}
+
+// categorySubtree selects the ID of a category and of all its descendants.
+const categorySubtree = `WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id = ?
+		UNION ALL
+		SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+	) SELECT id FROM subtree`
+
+func (c *Category) Create(db *sql.DB) error {
+	query := "INSERT INTO categories (parent_id, name) VALUES ($1, $2) RETURNING id"
+	err := db.QueryRow(query, c.ParentID, c.Name).Scan(&c.ID)
+	if err != nil {
+		return categoryError("could not create category", err)
+	}
+	return nil
+}
+
+func (c *Category) GetByID(db *sql.DB, id int) error {
+	query := "SELECT id, parent_id, name FROM categories WHERE id = $1"
+	err := db.QueryRow(query, id).Scan(&c.ID, &c.ParentID, &c.Name)
+	if err == sql.ErrNoRows {
+		return ErrCategoryNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not get category by id: %v", err)
+	}
+	return nil
+}
+
+// Update renames or moves the category. Moving it below itself or one of
+// its descendants fails with ErrCategoryCycle.
+func (c *Category) Update(db *sql.DB) error {
+	b := &QueryBuilder{}
+	query := fmt.Sprintf("UPDATE categories SET parent_id = %s, name = %s", b.Arg(c.ParentID), b.Arg(c.Name))
+	b.Where("id = ?", c.ID)
+	if c.ParentID != nil {
+		b.Where("? NOT IN ("+categorySubtree+")", *c.ParentID, c.ID)
+	}
+
+	res, err := db.Exec(query+b.WhereClause(), b.Args()...)
+	if err != nil {
+		return categoryError("could not update category", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not update category: %v", err)
+	}
+	if n == 0 {
+		// Either the category is gone or the new parent is in its subtree
+		var existing Category
+		err := existing.GetByID(db, c.ID)
+		if err != nil {
+			return err
+		}
+		return ErrCategoryCycle
+	}
+	return nil
+}
+
+// Delete removes the category and its product assignments. Categories with
+// subcategories can't be deleted.
+func (c *Category) Delete(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM categories WHERE id = $1", c.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
+			return ErrCategoryHasChildren
+		}
+		return fmt.Errorf("could not delete category: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete category: %v", err)
+	}
+	if n == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// GetAllCategories returns the whole taxonomy, ordered by name. Clients
+// build the tree from each category's ParentID.
+func GetAllCategories(db *sql.DB) ([]Category, error) {
+	rows, err := db.Query("SELECT id, parent_id, name FROM categories ORDER BY name, id")
+	if err != nil {
+		return nil, fmt.Errorf("could not get categories: %v", err)
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		err := rows.Scan(&c.ID, &c.ParentID, &c.Name)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan category: %v", err)
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, nil
+}
+
+// GetProductCategories returns the categories a product is assigned to.
+func GetProductCategories(db *sql.DB, productID int) ([]Category, error) {
+	query := `SELECT categories.id, categories.parent_id, categories.name
+			  FROM categories JOIN product_categories ON product_categories.category_id = categories.id
+			  WHERE product_categories.product_id = $1 ORDER BY categories.name, categories.id`
+	rows, err := db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get product categories: %v", err)
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var c Category
+		err := rows.Scan(&c.ID, &c.ParentID, &c.Name)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan category: %v", err)
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, nil
+}
+
+// SetProductCategories replaces the categories a product is assigned to. It
+// fails with ErrUnknownCategory if one of them doesn't exist.
+func SetProductCategories(db *sql.DB, productID int, categoryIDs []int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not set product categories: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM product_categories WHERE product_id = $1", productID)
+	if err != nil {
+		return fmt.Errorf("could not set product categories: %v", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO product_categories (product_id, category_id)
+			  SELECT $1, category_id FROM unnest($2::int[]) AS category_id
+			  ON CONFLICT DO NOTHING`, productID, pq.Array(categoryIDs))
+	if err != nil {
+		return categoryError("could not set product categories", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not set product categories: %v", err)
+	}
+	return nil
+}
+
+// categoryError maps constraint violations to the category errors and wraps
+// everything else.
+func categoryError(msg string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pqForeignKeyViolation:
+			return ErrUnknownCategory
+		case pqUniqueViolation:
+			return ErrCategoryExists
+		}
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
@@ -0,0 +1,340 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Rounding is how converted amounts are rounded to the decimal places of
+// their currency.
+type Rounding string
+
+const (
+	// RoundHalfEven rounds halves to the even neighbour, e.g. 1.125 to
+	// 1.12 and 1.135 to 1.14. It is the default, as it doesn't bias totals.
+	RoundHalfEven Rounding = "half_even"
+	// RoundHalfUp rounds halves away from zero.
+	RoundHalfUp Rounding = "half_up"
+	// RoundDown truncates towards zero.
+	RoundDown Rounding = "down"
+	// RoundUp rounds away from zero.
+	RoundUp Rounding = "up"
+)
+
+var (
+	ErrNoExchangeRate      = errors.New("no exchange rate")
+	ErrInvalidExchangeRate = errors.New("invalid exchange rate")
+	ErrInvalidRounding     = errors.New("invalid rounding")
+)
+
+// ExchangeRate is the price of one unit of Base in Quote.
+type ExchangeRate struct {
+	Base      string          `json:"base"`
+	Quote     string          `json:"quote"`
+	Rate      decimal.Decimal `json:"rate"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ConvertedPrice is a price in the currency a client asked for. Rate is the
+// exchange rate it was converted with; it is unset when the product has a
+// price of its own in that currency.
+type ConvertedPrice struct {
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+	// EffectivePrice is the effective price in Currency, when the product
+	// was read with its promotion
+	EffectivePrice *decimal.Decimal `json:"effective_price,omitempty"`
+	Rate           *decimal.Decimal `json:"rate,omitempty"`
+	RateUpdatedAt  *time.Time       `json:"rate_updated_at,omitempty"`
+}
+
+// ParseRounding parses a rounding mode. An empty string yields
+// RoundHalfEven.
+func ParseRounding(s string) (Rounding, error) {
+	switch r := Rounding(s); r {
+	case "":
+		return RoundHalfEven, nil
+	case RoundHalfEven, RoundHalfUp, RoundDown, RoundUp:
+		return r, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidRounding, s)
+}
+
+// Round rounds d to scale decimal places.
+func (r Rounding) Round(d decimal.Decimal, scale int32) decimal.Decimal {
+	switch r {
+	case RoundHalfUp:
+		return d.Round(scale)
+	case RoundDown:
+		return d.RoundDown(scale)
+	case RoundUp:
+		return d.RoundUp(scale)
+	default:
+		return d.RoundBank(scale)
+	}
+}
+
+// Validate normalizes the currencies and checks that the rate is positive.
+func (e *ExchangeRate) Validate() error {
+	base, err := NormalizeCurrency(e.Base)
+	if err != nil {
+		return err
+	}
+	quote, err := NormalizeCurrency(e.Quote)
+	if err != nil {
+		return err
+	}
+	if base == quote || !e.Rate.IsPositive() {
+		return fmt.Errorf("%w: %s/%s %s", ErrInvalidExchangeRate, base, quote, e.Rate)
+	}
+	e.Base, e.Quote = base, quote
+	return nil
+}
+
+// ParseExchangeRatesCSV reads "base,quote,rate" records, such as
+// "EUR,USD,1.0842". A header line naming those columns is skipped.
+func ParseExchangeRatesCSV(r io.Reader) ([]ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+
+	rates := []ExchangeRate{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+		}
+		if line == 1 && strings.EqualFold(record[0], "base") {
+			continue
+		}
+
+		rate, err := decimal.NewFromString(strings.TrimSpace(record[2]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidExchangeRate, line, err)
+		}
+		rates = append(rates, ExchangeRate{Base: record[0], Quote: record[1], Rate: rate})
+	}
+
+	return rates, nil
+}
+
+// SetExchangeRates stores rates, replacing the previous rates of the same
+// currency pairs. The rates must have been validated.
+func SetExchangeRates(db *sql.DB, rates []ExchangeRate) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not set exchange rates: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO exchange_rates (base, quote, rate, updated_at) VALUES ($1, $2, $3, NOW())
+			  ON CONFLICT (base, quote) DO UPDATE SET rate = EXCLUDED.rate, updated_at = EXCLUDED.updated_at`
+	for _, rate := range rates {
+		_, err := tx.Exec(query, rate.Base, rate.Quote, rate.Rate)
+		if err != nil {
+			return fmt.Errorf("could not set exchange rates: %v", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not set exchange rates: %v", err)
+	}
+	return nil
+}
+
+// GetExchangeRates returns every stored rate, ordered by currency pair.
+func GetExchangeRates(db *sql.DB) ([]ExchangeRate, error) {
+	rows, err := db.Query("SELECT base, quote, rate, updated_at FROM exchange_rates ORDER BY base, quote")
+	if err != nil {
+		return nil, fmt.Errorf("could not get exchange rates: %v", err)
+	}
+	defer rows.Close()
+
+	rates := []ExchangeRate{}
+	for rows.Next() {
+		var e ExchangeRate
+		err := rows.Scan(&e.Base, &e.Quote, &e.Rate, &e.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan exchange rate: %v", err)
+		}
+		rates = append(rates, e)
+	}
+
+	return rates, nil
+}
+
+// getExchangeRatesTo returns the rates converting into quote, keyed by base
+// currency. Where only the opposite pair is stored its inverse is used.
+func getExchangeRatesTo(db *sql.DB, quote string) (map[string]ExchangeRate, error) {
+	query := `SELECT base, rate, updated_at FROM exchange_rates WHERE quote = $1
+			  UNION ALL
+			  SELECT quote, 1 / rate, updated_at FROM exchange_rates e WHERE base = $1
+			  AND NOT EXISTS (SELECT 1 FROM exchange_rates WHERE base = e.quote AND quote = $1)`
+	rows, err := db.Query(query, quote)
+	if err != nil {
+		return nil, fmt.Errorf("could not get exchange rates: %v", err)
+	}
+	defer rows.Close()
+
+	rates := make(map[string]ExchangeRate)
+	for rows.Next() {
+		e := ExchangeRate{Quote: quote}
+		err := rows.Scan(&e.Base, &e.Rate, &e.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan exchange rate: %v", err)
+		}
+		rates[e.Base] = e
+	}
+
+	return rates, nil
+}
+
+// ConvertProducts sets the ConvertedPrice of products and their variants to
+// their price in currency. A product's own price in that currency is used
+// when it has one; otherwise the price is converted with the stored exchange
+// rate and rounded. It fails with ErrNoExchangeRate if a product can't be
+// converted. Variants are copied, not modified in place, as products may be
+// shared with a cache.
+func ConvertProducts(db *sql.DB, products []*Product, currency string, rounding Rounding) error {
+	if len(products) == 0 {
+		return nil
+	}
+	if _, err := CurrencyScale(currency); err != nil {
+		return err
+	}
+
+	rates, err := getExchangeRatesTo(db, currency)
+	if err != nil {
+		return err
+	}
+	listPrices, err := getListPrices(db, products, currency)
+	if err != nil {
+		return err
+	}
+
+	return ConvertProductPrices(products, currency, rates, listPrices, rounding)
+}
+
+// ConvertProductPrices is ConvertProducts with the exchange rates to
+// currency, keyed by base currency, and the products' own prices in it,
+// keyed by product ID, already loaded. A rate is only needed for products
+// without their own price and for variants with a price override. Effective
+// prices are converted too; a promotion takes the same share off a
+// product's own price as off its price in its currency.
+func ConvertProductPrices(products []*Product, currency string, rates map[string]ExchangeRate, listPrices map[int]decimal.Decimal, rounding Rounding) error {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		// Looked up on first use, so that products with their own price
+		// don't need a rate
+		var rate *ExchangeRate
+		rateFor := func() (*ExchangeRate, error) {
+			if p.Currency == currency || rate != nil {
+				return rate, nil
+			}
+			r, ok := rates[p.Currency]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s to %s", ErrNoExchangeRate, p.Currency, currency)
+			}
+			rate = &r
+			return rate, nil
+		}
+
+		if amount, ok := listPrices[p.ID]; ok {
+			p.ConvertedPrice = &ConvertedPrice{Currency: currency, Amount: amount}
+			if p.EffectivePrice != nil {
+				effective := amount
+				if !p.ProductPrice.IsZero() {
+					effective = rounding.Round(amount.Mul(*p.EffectivePrice).Div(p.ProductPrice), scale)
+				}
+				p.ConvertedPrice.EffectivePrice = &effective
+			}
+		} else {
+			r, err := rateFor()
+			if err != nil {
+				return err
+			}
+			p.ConvertedPrice = convertPrice(p.ProductPrice, p.EffectivePrice, currency, scale, r, rounding)
+		}
+
+		variants := make([]Variant, len(p.Variants))
+		for i, v := range p.Variants {
+			if v.PriceOverride == nil {
+				v.ConvertedPrice = p.ConvertedPrice
+			} else {
+				r, err := rateFor()
+				if err != nil {
+					return err
+				}
+				v.ConvertedPrice = convertPrice(*v.PriceOverride, v.EffectivePrice, currency, scale, r, rounding)
+			}
+			variants[i] = v
+		}
+		if p.Variants != nil {
+			p.Variants = variants
+		}
+	}
+
+	return nil
+}
+
+// convertPrice converts amount and the effective price, if any, with rate,
+// or returns them as is if rate is nil because they are already in currency.
+func convertPrice(amount decimal.Decimal, effective *decimal.Decimal, currency string, scale int32, rate *ExchangeRate, rounding Rounding) *ConvertedPrice {
+	if rate == nil {
+		return &ConvertedPrice{Currency: currency, Amount: amount, EffectivePrice: effective}
+	}
+	converted := &ConvertedPrice{
+		Currency:      currency,
+		Amount:        rounding.Round(amount.Mul(rate.Rate), scale),
+		Rate:          &rate.Rate,
+		RateUpdatedAt: &rate.UpdatedAt,
+	}
+	if effective != nil {
+		price := rounding.Round(effective.Mul(rate.Rate), scale)
+		converted.EffectivePrice = &price
+	}
+	return converted
+}
+
+// getListPrices returns the prices set in currency for the products, keyed
+// by product ID.
+func getListPrices(db *sql.DB, products []*Product, currency string) (map[int]decimal.Decimal, error) {
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+
+	rows, err := db.Query("SELECT product_id, amount FROM product_prices WHERE product_id = ANY($1) AND currency = $2", pq.Array(ids), currency)
+	if err != nil {
+		return nil, fmt.Errorf("could not get product prices: %v", err)
+	}
+	defer rows.Close()
+
+	prices := make(map[int]decimal.Decimal)
+	for rows.Next() {
+		var id int
+		var amount decimal.Decimal
+		err := rows.Scan(&id, &amount)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan price: %v", err)
+		}
+		prices[id] = amount
+	}
+
+	return prices, nil
+}
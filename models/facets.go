@@ -0,0 +1,184 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultPriceBuckets are the boundaries of the price facet when a request
+// doesn't give its own.
+var DefaultPriceBuckets = []decimal.Decimal{
+	decimal.NewFromInt(10), decimal.NewFromInt(25), decimal.NewFromInt(50),
+	decimal.NewFromInt(100), decimal.NewFromInt(250),
+}
+
+var ErrInvalidPriceBuckets = errors.New("price bucket boundaries must be ascending")
+
+// FacetOptions asks a listing for facet counts over all matching products.
+type FacetOptions struct {
+	// PriceBuckets are ascending boundaries. N boundaries give N+1 buckets:
+	// below the first, between each pair, and from the last one up.
+	PriceBuckets []decimal.Decimal
+}
+
+// Validate checks that the bucket boundaries are strictly ascending.
+func (o FacetOptions) Validate() error {
+	for i := 1; i < len(o.PriceBuckets); i++ {
+		if o.PriceBuckets[i].LessThanOrEqual(o.PriceBuckets[i-1]) {
+			return ErrInvalidPriceBuckets
+		}
+	}
+	return nil
+}
+
+// Facets are counts of the products matching a listing, across every page.
+type Facets struct {
+	Price      []PriceBucket   `json:"price"`
+	Images     ImageFacet      `json:"images"`
+	Categories []CategoryCount `json:"categories"`
+	Tags       []TagCount      `json:"tags"`
+}
+
+// PriceBucket counts products whose effective price is in [Min, Max). Min is unset for the
+// lowest bucket and Max for the highest.
+type PriceBucket struct {
+	Min   *decimal.Decimal `json:"min,omitempty"`
+	Max   *decimal.Decimal `json:"max,omitempty"`
+	Count int              `json:"count"`
+}
+
+type ImageFacet struct {
+	WithImages    int `json:"with_images"`
+	WithoutImages int `json:"without_images"`
+}
+
+// CategoryCount counts the products assigned directly to a category.
+type CategoryCount struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// facetBranches compute one facet each over the "matched" CTE. Every branch
+// yields (facet, value, label, count) rows so that they can be combined
+// with UNION ALL and fetched in a single query.
+var facetBranches = []string{
+	`SELECT 'price', width_bucket(effective_price, %[1]s::numeric[])::text, NULL, COUNT(*)
+	 FROM matched WHERE effective_price IS NOT NULL GROUP BY 2`,
+	`SELECT 'images', (COALESCE(cardinality(product_images), 0) > 0)::text, NULL, COUNT(*)
+	 FROM matched GROUP BY 2`,
+	`SELECT 'category', categories.id::text, categories.name, COUNT(*)
+	 FROM matched
+	 JOIN product_categories ON product_categories.product_id = matched.id
+	 JOIN categories ON categories.id = product_categories.category_id
+	 GROUP BY categories.id`,
+	`SELECT 'tag', product_tags.tag, NULL, COUNT(*)
+	 FROM matched JOIN product_tags ON product_tags.product_id = matched.id
+	 GROUP BY 2`,
+}
+
+// queryFacets computes every facet over the products selected by
+// "SELECT ... FROM <from>" with b's conditions. from must include
+// productsWithPromotion, as prices are bucketed by effective price. with
+// holds extra CTEs that from may refer to, such as a search query. b is left
+// unchanged.
+func queryFacets(db *sql.DB, with, from string, b *QueryBuilder, opts FacetOptions) (*Facets, error) {
+	buckets := opts.PriceBuckets
+	if buckets == nil {
+		buckets = DefaultPriceBuckets
+	}
+
+	fb := b.Clone()
+	bounds := make([]string, len(buckets))
+	for i, bound := range buckets {
+		bounds[i] = bound.String()
+	}
+	bucketsArg := fb.Arg(pq.Array(bounds))
+
+	branches := make([]string, len(facetBranches))
+	for i, branch := range facetBranches {
+		branches[i] = fmt.Sprintf(branch, bucketsArg)
+	}
+
+	query := `WITH ` + with + `matched AS (
+			  SELECT products.id, ` + effectivePrice + ` AS effective_price, product_images FROM ` + from + fb.WhereClause() + `
+			  ) ` + strings.Join(branches, " UNION ALL ")
+
+	rows, err := db.Query(query, fb.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get facets: %v", err)
+	}
+	defer rows.Close()
+
+	facets := newFacets(buckets)
+	for rows.Next() {
+		var facet, value string
+		var label sql.NullString
+		var count int
+		err := rows.Scan(&facet, &value, &label, &count)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan facet: %v", err)
+		}
+		facets.add(facet, value, label.String, count)
+	}
+
+	sort.Slice(facets.Categories, func(i, j int) bool {
+		a, b := facets.Categories[i], facets.Categories[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+	sort.Slice(facets.Tags, func(i, j int) bool {
+		a, b := facets.Tags[i], facets.Tags[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Tag < b.Tag
+	})
+
+	return facets, nil
+}
+
+func newFacets(buckets []decimal.Decimal) *Facets {
+	facets := &Facets{
+		Price:      make([]PriceBucket, len(buckets)+1),
+		Categories: []CategoryCount{},
+		Tags:       []TagCount{},
+	}
+	for i := range buckets {
+		facets.Price[i].Max = &buckets[i]
+		facets.Price[i+1].Min = &buckets[i]
+	}
+	return facets
+}
+
+func (f *Facets) add(facet, value, label string, count int) {
+	switch facet {
+	case "price":
+		bucket, err := strconv.Atoi(value)
+		if err == nil && bucket >= 0 && bucket < len(f.Price) {
+			f.Price[bucket].Count = count
+		}
+	case "images":
+		if value == "true" {
+			f.Images.WithImages = count
+		} else {
+			f.Images.WithoutImages = count
+		}
+	case "category":
+		id, err := strconv.Atoi(value)
+		if err == nil {
+			f.Categories = append(f.Categories, CategoryCount{ID: id, Name: label, Count: count})
+		}
+	case "tag":
+		f.Tags = append(f.Tags, TagCount{Tag: value, Count: count})
+	}
+}
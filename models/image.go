@@ -0,0 +1,40 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DefaultImageBackfillLimit is the number of images an image backfill
+// enqueues by default.
+const DefaultImageBackfillLimit = 1000
+
+// GetUncompressedImages returns up to limit distinct images of products
+// outside the trash that have fewer compressed images than images, because
+// processing them failed or they predate the image processor.
+func GetUncompressedImages(db *sql.DB, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = DefaultImageBackfillLimit
+	}
+
+	query := `SELECT DISTINCT image FROM products, unnest(product_images) AS image
+			  WHERE deleted_at IS NULL AND cardinality(compressed_product_images) < cardinality(product_images)
+			  ORDER BY image LIMIT $1`
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get uncompressed images: %v", err)
+	}
+	defer rows.Close()
+
+	images := []string{}
+	for rows.Next() {
+		var image string
+		err := rows.Scan(&image)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan image: %v", err)
+		}
+		images = append(images, image)
+	}
+
+	return images, nil
+}
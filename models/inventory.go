@@ -0,0 +1,333 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reasons recorded in the inventory ledger. Adjustments made through the API
+// use the first four; the others are recorded by reservations.
+const (
+	ReasonRestock    = "restock"
+	ReasonDamage     = "damage"
+	ReasonReturn     = "return"
+	ReasonCorrection = "correction"
+	ReasonReserve    = "reserve"
+	ReasonRelease    = "release"
+	ReasonSale       = "sale"
+)
+
+// Reservation statuses. Only pending reservations can be released or
+// committed.
+const (
+	ReservationPending   = "pending"
+	ReservationReleased  = "released"
+	ReservationCommitted = "committed"
+)
+
+var (
+	ErrInsufficientStock     = errors.New("insufficient stock")
+	ErrInvalidQuantity       = errors.New("invalid quantity")
+	ErrInvalidReason         = errors.New("invalid adjustment reason")
+	ErrReservationNotFound   = errors.New("reservation not found")
+	ErrReservationNotPending = errors.New("reservation is no longer pending")
+)
+
+// adjustmentReasons are the reasons accepted by AdjustStock.
+var adjustmentReasons = map[string]bool{
+	ReasonRestock:    true,
+	ReasonDamage:     true,
+	ReasonReturn:     true,
+	ReasonCorrection: true,
+}
+
+// Stock is the inventory of a product, or of one of its variants when
+// VariantID is set. Reserved units are held for pending reservations and
+// can't be sold to anyone else.
+type Stock struct {
+	ProductID         int  `json:"product_id"`
+	VariantID         int  `json:"variant_id,omitempty"`
+	OnHand            int  `json:"on_hand"`
+	Reserved          int  `json:"reserved"`
+	Available         int  `json:"available"`
+	LowStockThreshold int  `json:"low_stock_threshold"`
+	LowStock          bool `json:"low_stock"`
+}
+
+// StockAdjustment is an entry of the inventory ledger.
+type StockAdjustment struct {
+	ID            int       `json:"id"`
+	ProductID     int       `json:"product_id"`
+	VariantID     int       `json:"variant_id,omitempty"`
+	OnHandDelta   int       `json:"on_hand_delta"`
+	ReservedDelta int       `json:"reserved_delta"`
+	Reason        string    `json:"reason"`
+	Note          string    `json:"note,omitempty"`
+	ReservationID int       `json:"reservation_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Reservation holds stock until it is committed as a sale or released.
+type Reservation struct {
+	ID        int       `json:"id"`
+	ProductID int       `json:"product_id"`
+	VariantID int       `json:"variant_id,omitempty"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// stockColumns are selected by every stock query, in scan order.
+const stockColumns = `product_id, COALESCE(variant_id, 0), on_hand, reserved, low_stock_threshold`
+
+// stockItem selects the inventory row of a product ($1) or variant ($2, zero
+// for the product itself). It matches idx_inventory_item.
+const stockItem = `product_id = $1 AND COALESCE(variant_id, 0) = $2`
+
+// ensureStock creates the inventory row of an item if it doesn't exist yet.
+const ensureStock = `INSERT INTO inventory (product_id, variant_id) VALUES ($1, NULLIF($2, 0))
+	ON CONFLICT (product_id, COALESCE(variant_id, 0)) DO NOTHING`
+
+func (s *Stock) scan(row scanner) error {
+	err := row.Scan(&s.ProductID, &s.VariantID, &s.OnHand, &s.Reserved, &s.LowStockThreshold)
+	if err != nil {
+		return err
+	}
+	s.Available = s.OnHand - s.Reserved
+	s.LowStock = s.Available <= s.LowStockThreshold
+	return nil
+}
+
+// GetProductStock returns the inventory of a product and its variants. Items
+// that were never stocked are left out.
+func GetProductStock(db *sql.DB, productID int) ([]Stock, error) {
+	query := `SELECT ` + stockColumns + ` FROM inventory WHERE product_id = $1 ORDER BY COALESCE(variant_id, 0)`
+	rows, err := db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get stock: %v", err)
+	}
+	defer rows.Close()
+
+	stock := []Stock{}
+	for rows.Next() {
+		var s Stock
+		err := s.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan stock: %v", err)
+		}
+		stock = append(stock, s)
+	}
+
+	return stock, nil
+}
+
+// AdjustStock changes the units on hand by delta and records it in the
+// ledger. It fails with ErrInsufficientStock if fewer units than are
+// reserved would be left.
+func AdjustStock(db *sql.DB, productID, variantID, delta int, reason, note string) (*Stock, error) {
+	if !adjustmentReasons[reason] {
+		return nil, ErrInvalidReason
+	}
+	if delta == 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not adjust stock: %v", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(ensureStock, productID, variantID)
+	if err != nil {
+		return nil, fmt.Errorf("could not adjust stock: %v", err)
+	}
+
+	// The row lock taken by the update serializes concurrent adjustments
+	var stock Stock
+	query := `UPDATE inventory SET on_hand = on_hand + $3
+			  WHERE ` + stockItem + ` AND on_hand + $3 >= reserved
+			  RETURNING ` + stockColumns
+	err = stock.scan(tx.QueryRow(query, productID, variantID, delta))
+	if err == sql.ErrNoRows {
+		return nil, ErrInsufficientStock
+	} else if err != nil {
+		return nil, fmt.Errorf("could not adjust stock: %v", err)
+	}
+
+	err = recordAdjustment(tx, productID, variantID, delta, 0, reason, note, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not adjust stock: %v", err)
+	}
+	return &stock, nil
+}
+
+// SetLowStockThreshold sets the available quantity at or below which an item
+// is listed as low on stock.
+func SetLowStockThreshold(db *sql.DB, productID, variantID, threshold int) (*Stock, error) {
+	if threshold < 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	_, err := db.Exec(ensureStock, productID, variantID)
+	if err != nil {
+		return nil, fmt.Errorf("could not set low stock threshold: %v", err)
+	}
+
+	var stock Stock
+	query := `UPDATE inventory SET low_stock_threshold = $3 WHERE ` + stockItem + ` RETURNING ` + stockColumns
+	err = stock.scan(db.QueryRow(query, productID, variantID, threshold))
+	if err != nil {
+		return nil, fmt.Errorf("could not set low stock threshold: %v", err)
+	}
+	return &stock, nil
+}
+
+// GetStockAdjustments returns the ledger of a product and its variants, most
+// recent first.
+func GetStockAdjustments(db *sql.DB, productID, limit int) ([]StockAdjustment, error) {
+	limit = PageOptions{Limit: limit}.limit()
+	query := `SELECT id, product_id, COALESCE(variant_id, 0), on_hand_delta, reserved_delta, reason, COALESCE(note, ''), COALESCE(reservation_id, 0), created_at
+			  FROM inventory_adjustments WHERE product_id = $1 ORDER BY id DESC LIMIT $2`
+	rows, err := db.Query(query, productID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get stock adjustments: %v", err)
+	}
+	defer rows.Close()
+
+	adjustments := []StockAdjustment{}
+	for rows.Next() {
+		var a StockAdjustment
+		err := rows.Scan(&a.ID, &a.ProductID, &a.VariantID, &a.OnHandDelta, &a.ReservedDelta, &a.Reason, &a.Note, &a.ReservationID, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan stock adjustment: %v", err)
+		}
+		adjustments = append(adjustments, a)
+	}
+
+	return adjustments, nil
+}
+
+// ReserveStock holds quantity units of an item. It fails with
+// ErrInsufficientStock unless that many units are available.
+func ReserveStock(db *sql.DB, productID, variantID, quantity int) (*Reservation, error) {
+	if quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve stock: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Checking availability and reserving in one statement keeps two
+	// reservations from taking the same units
+	res, err := tx.Exec(`UPDATE inventory SET reserved = reserved + $3
+			  WHERE `+stockItem+` AND on_hand - reserved >= $3`, productID, variantID, quantity)
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve stock: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve stock: %v", err)
+	}
+	if n == 0 {
+		return nil, ErrInsufficientStock
+	}
+
+	reservation := &Reservation{ProductID: productID, VariantID: variantID, Quantity: quantity, Status: ReservationPending}
+	query := `INSERT INTO inventory_reservations (product_id, variant_id, quantity, status)
+			  VALUES ($1, NULLIF($2, 0), $3, $4) RETURNING id, created_at`
+	err = tx.QueryRow(query, productID, variantID, quantity, ReservationPending).Scan(&reservation.ID, &reservation.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve stock: %v", err)
+	}
+
+	err = recordAdjustment(tx, productID, variantID, 0, quantity, ReasonReserve, "", reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not reserve stock: %v", err)
+	}
+	return reservation, nil
+}
+
+// ReleaseReservation returns the units held by a pending reservation.
+func ReleaseReservation(db *sql.DB, id int) (*Reservation, error) {
+	return settleReservation(db, id, ReservationReleased, ReasonRelease, 0)
+}
+
+// CommitReservation sells the units held by a pending reservation, removing
+// them from stock.
+func CommitReservation(db *sql.DB, id int) (*Reservation, error) {
+	return settleReservation(db, id, ReservationCommitted, ReasonSale, -1)
+}
+
+// settleReservation moves a pending reservation to status, dropping its
+// units from reserved and adding onHandSign times them to on hand.
+func settleReservation(db *sql.DB, id int, status, reason string, onHandSign int) (*Reservation, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not settle reservation: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Only one of concurrent settlements finds the reservation pending
+	var r Reservation
+	query := `UPDATE inventory_reservations SET status = $2
+			  WHERE id = $1 AND status = $3
+			  RETURNING id, product_id, COALESCE(variant_id, 0), quantity, status, created_at`
+	err = tx.QueryRow(query, id, status, ReservationPending).Scan(&r.ID, &r.ProductID, &r.VariantID, &r.Quantity, &r.Status, &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		var exists bool
+		err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM inventory_reservations WHERE id = $1)", id).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("could not settle reservation: %v", err)
+		}
+		if !exists {
+			return nil, ErrReservationNotFound
+		}
+		return nil, ErrReservationNotPending
+	} else if err != nil {
+		return nil, fmt.Errorf("could not settle reservation: %v", err)
+	}
+
+	onHandDelta := onHandSign * r.Quantity
+	_, err = tx.Exec(`UPDATE inventory SET reserved = reserved - $3, on_hand = on_hand + $4 WHERE `+stockItem,
+		r.ProductID, r.VariantID, r.Quantity, onHandDelta)
+	if err != nil {
+		return nil, fmt.Errorf("could not settle reservation: %v", err)
+	}
+
+	err = recordAdjustment(tx, r.ProductID, r.VariantID, onHandDelta, -r.Quantity, reason, "", r.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not settle reservation: %v", err)
+	}
+	return &r, nil
+}
+
+func recordAdjustment(tx *sql.Tx, productID, variantID, onHandDelta, reservedDelta int, reason, note string, reservationID int) error {
+	query := `INSERT INTO inventory_adjustments (product_id, variant_id, on_hand_delta, reserved_delta, reason, note, reservation_id)
+			  VALUES ($1, NULLIF($2, 0), $3, $4, $5, NULLIF($6, ''), NULLIF($7, 0))`
+	_, err := tx.Exec(query, productID, variantID, onHandDelta, reservedDelta, reason, note, reservationID)
+	if err != nil {
+		return fmt.Errorf("could not record stock adjustment: %v", err)
+	}
+	return nil
+}
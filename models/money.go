@@ -0,0 +1,84 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency is the currency of products created without one.
+var DefaultCurrency = "USD"
+
+// maxPriceDigits is the number of integer digits the price columns hold.
+const maxPriceDigits = 12
+
+// currencyScales are the ISO 4217 currencies prices may be given in, with
+// the number of decimal places each allows.
+var currencyScales = map[string]int32{
+	"AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2,
+	"DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "INR": 2, "JPY": 0,
+	"KRW": 0, "KWD": 3, "MXN": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PLN": 2,
+	"SEK": 2, "SGD": 2, "TRY": 2, "USD": 2, "ZAR": 2,
+}
+
+var (
+	ErrUnknownCurrency = errors.New("unknown currency")
+	ErrInvalidPrice    = errors.New("invalid price")
+)
+
+// Price is an amount of money in a currency.
+type Price struct {
+	Currency string          `json:"currency"`
+	Amount   decimal.Decimal `json:"amount"`
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and checks that it is
+// supported.
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if _, ok := currencyScales[currency]; !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return currency, nil
+}
+
+// CurrencyScale returns the number of decimal places of a currency.
+func CurrencyScale(currency string) (int32, error) {
+	scale, ok := currencyScales[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return scale, nil
+}
+
+// ValidatePrice checks that amount is a non-negative price with no more
+// decimal places than currency allows, e.g. 19.99 USD but not 19.999 USD or
+// 1.5 JPY.
+func ValidatePrice(amount decimal.Decimal, currency string) error {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return err
+	}
+	if amount.IsNegative() {
+		return fmt.Errorf("%w: %s is negative", ErrInvalidPrice, amount)
+	}
+	if !amount.Equal(amount.Truncate(scale)) {
+		return fmt.Errorf("%w: %s %s has more than %d decimal places", ErrInvalidPrice, amount, currency, scale)
+	}
+	if amount.GreaterThanOrEqual(decimal.New(1, maxPriceDigits)) {
+		return fmt.Errorf("%w: %s is too large", ErrInvalidPrice, amount)
+	}
+	return nil
+}
+
+// Validate normalizes the currency and validates the amount.
+func (p *Price) Validate() error {
+	currency, err := NormalizeCurrency(p.Currency)
+	if err != nil {
+		return err
+	}
+	p.Currency = currency
+	return ValidatePrice(p.Amount, p.Currency)
+}
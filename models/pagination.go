@@ -0,0 +1,164 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPageLimit is the page size used when a listing doesn't ask for one.
+	DefaultPageLimit = 50
+	// MaxPageLimit caps the page size a listing may ask for.
+	MaxPageLimit = 100
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// sortColumn maps a sort field accepted by listings to its column and the
+// type its cursor value is cast to.
+type sortColumn struct {
+	column string
+	cast   string
+}
+
+var sortColumns = map[string]sortColumn{
+	"price":      {column: effectivePrice, cast: "numeric"},
+	"name":       {column: "product_name", cast: "text"},
+	"created_at": {column: "created_at", cast: "timestamptz"},
+}
+
+// ProductSort is the ordering of a product listing. Ties are broken by ID in
+// the same direction, which keeps keyset pagination stable.
+type ProductSort struct {
+	Field string
+	Desc  bool
+}
+
+// DefaultProductSort lists the newest products first.
+var DefaultProductSort = ProductSort{Field: "created_at", Desc: true}
+
+// ParseProductSort parses "field" or "field:asc|desc", e.g. "price:desc".
+// An empty string yields DefaultProductSort.
+func ParseProductSort(s string) (ProductSort, error) {
+	if s == "" {
+		return DefaultProductSort, nil
+	}
+
+	field, direction := s, "asc"
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		field, direction = s[:i], s[i+1:]
+	}
+	if _, ok := sortColumns[field]; !ok {
+		return ProductSort{}, fmt.Errorf("%w: unknown field %q", ErrInvalidSort, field)
+	}
+	if direction != "asc" && direction != "desc" {
+		return ProductSort{}, fmt.Errorf("%w: unknown direction %q", ErrInvalidSort, direction)
+	}
+
+	return ProductSort{Field: field, Desc: direction == "desc"}, nil
+}
+
+func (s ProductSort) String() string {
+	if s.Desc {
+		return s.Field + ":desc"
+	}
+	return s.Field + ":asc"
+}
+
+// orderBy returns the ORDER BY clause for the sort.
+func (s ProductSort) orderBy() string {
+	direction := "ASC"
+	if s.Desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", sortColumns[s.Field].column, direction, direction)
+}
+
+// applyAfter adds the keyset condition selecting rows past the cursor.
+func (s ProductSort) applyAfter(b *QueryBuilder, c *pageCursor) {
+	op := ">"
+	if s.Desc {
+		op = "<"
+	}
+	column := sortColumns[s.Field]
+	b.Where(fmt.Sprintf("(%s, id) %s (?::%s, ?)", column.column, op, column.cast), c.Value, c.ID)
+}
+
+// value returns the sort key of p as it is stored in a cursor.
+func (s ProductSort) value(p Product) string {
+	switch s.Field {
+	case "price":
+		if p.EffectivePrice != nil {
+			return p.EffectivePrice.String()
+		}
+		return p.ProductPrice.String()
+	case "name":
+		return p.ProductName
+	default:
+		return p.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// PageOptions selects a page of a product listing. Facets is nil unless
+// facet counts are wanted.
+type PageOptions struct {
+	Limit        int
+	Cursor       string
+	Sort         ProductSort
+	IncludeTotal bool
+	Facets       *FacetOptions
+}
+
+// ProductPage is a page of a product listing. NextCursor is empty on the
+// last page, and TotalCount and Facets are only set when they were asked for.
+type ProductPage struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	TotalCount *int      `json:"total_count,omitempty"`
+	Facets     *Facets   `json:"facets,omitempty"`
+}
+
+// pageCursor is the decoded form of the opaque cursor handed to clients. It
+// records the sort it was issued for so it can't be reused with another.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeCursor(sort ProductSort, last Product) string {
+	data, _ := json.Marshal(pageCursor{Sort: sort.String(), Value: sort.value(last), ID: last.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(sort ProductSort, cursor string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c pageCursor
+	err = json.Unmarshal(data, &c)
+	if err != nil || c.Sort != sort.String() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// limit returns the page size to use, applying the default and the cap.
+func (o PageOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if o.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return o.Limit
+}
@@ -0,0 +1,259 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sources of a price change.
+const (
+	PriceChangeManual    = "manual"
+	PriceChangeScheduled = "scheduled"
+)
+
+// Statuses of a scheduled price change.
+const (
+	ScheduledPricePending   = "pending"
+	ScheduledPriceApplied   = "applied"
+	ScheduledPriceCancelled = "cancelled"
+)
+
+var (
+	ErrScheduledPriceNotFound   = errors.New("scheduled price change not found")
+	ErrScheduledPriceNotPending = errors.New("scheduled price change is no longer pending")
+	ErrScheduledPriceInPast     = errors.New("scheduled price change must be in the future")
+)
+
+// PriceChange is an entry of a product's price history. ChangedBy is the
+// user who made the change, or who scheduled it, and is zero when unknown.
+// ScheduledChangeID is set when the scheduler applied the change.
+type PriceChange struct {
+	ID                int       `json:"id"`
+	ProductID         int       `json:"product_id"`
+	OldPrice          Price     `json:"old_price"`
+	NewPrice          Price     `json:"new_price"`
+	ChangedBy         int       `json:"changed_by,omitempty"`
+	Source            string    `json:"source"`
+	ScheduledChangeID int       `json:"scheduled_change_id,omitempty"`
+	ChangedAt         time.Time `json:"changed_at"`
+}
+
+// ScheduledPrice is a price change to be applied at ApplyAt.
+type ScheduledPrice struct {
+	ID        int        `json:"id"`
+	ProductID int        `json:"product_id"`
+	Price     Price      `json:"price"`
+	ApplyAt   time.Time  `json:"apply_at"`
+	Status    string     `json:"status"`
+	CreatedBy int        `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// AppliedPrice is a scheduled price change that ApplyDuePrices applied, with
+// the owner of the product so that caches can be invalidated.
+type AppliedPrice struct {
+	ScheduledPrice
+	UserID int
+}
+
+// GetPriceHistory returns the price changes of a product, most recent first.
+func GetPriceHistory(db *sql.DB, productID, limit int) ([]PriceChange, error) {
+	limit = PageOptions{Limit: limit}.limit()
+	query := `SELECT id, product_id, old_price, old_currency, new_price, new_currency, COALESCE(changed_by, 0), source, COALESCE(scheduled_change_id, 0), changed_at
+			  FROM price_history WHERE product_id = $1 ORDER BY changed_at DESC, id DESC LIMIT $2`
+	rows, err := db.Query(query, productID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get price history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []PriceChange{}
+	for rows.Next() {
+		var c PriceChange
+		err := rows.Scan(&c.ID, &c.ProductID, &c.OldPrice.Amount, &c.OldPrice.Currency, &c.NewPrice.Amount, &c.NewPrice.Currency, &c.ChangedBy, &c.Source, &c.ScheduledChangeID, &c.ChangedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan price change: %v", err)
+		}
+		history = append(history, c)
+	}
+
+	return history, nil
+}
+
+// recordPriceChange adds a price change to the history, unless the price
+// stayed the same.
+func recordPriceChange(tx *sql.Tx, productID int, from, to Price, actorID int, source string, scheduledChangeID int) error {
+	if from.Currency == to.Currency && from.Amount.Equal(to.Amount) {
+		return nil
+	}
+
+	query := `INSERT INTO price_history (product_id, old_price, old_currency, new_price, new_currency, changed_by, source, scheduled_change_id)
+			  VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7, NULLIF($8, 0))`
+	_, err := tx.Exec(query, productID, from.Amount, from.Currency, to.Amount, to.Currency, actorID, source, scheduledChangeID)
+	if err != nil {
+		return fmt.Errorf("could not record price change: %v", err)
+	}
+	return nil
+}
+
+// Create schedules the price change. The price must have been validated.
+func (s *ScheduledPrice) Create(db *sql.DB) error {
+	if !s.ApplyAt.After(time.Now()) {
+		return ErrScheduledPriceInPast
+	}
+
+	s.Status = ScheduledPricePending
+	query := `INSERT INTO scheduled_prices (product_id, price, currency, apply_at, status, created_by)
+			  VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0)) RETURNING id, created_at`
+	err := db.QueryRow(query, s.ProductID, s.Price.Amount, s.Price.Currency, s.ApplyAt, s.Status, s.CreatedBy).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("could not schedule price change: %v", err)
+	}
+	return nil
+}
+
+// scheduledPriceColumns are selected by every scheduled price query, in the
+// order scanned by scan.
+const scheduledPriceColumns = `id, product_id, price, currency, apply_at, status, COALESCE(created_by, 0), created_at, applied_at`
+
+func (s *ScheduledPrice) scan(row scanner) error {
+	return row.Scan(&s.ID, &s.ProductID, &s.Price.Amount, &s.Price.Currency, &s.ApplyAt, &s.Status, &s.CreatedBy, &s.CreatedAt, &s.AppliedAt)
+}
+
+// GetScheduledPrices returns the price changes scheduled for a product, in
+// the order they apply.
+func GetScheduledPrices(db *sql.DB, productID int) ([]ScheduledPrice, error) {
+	query := `SELECT ` + scheduledPriceColumns + ` FROM scheduled_prices WHERE product_id = $1 ORDER BY apply_at, id`
+	rows, err := db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get scheduled prices: %v", err)
+	}
+	defer rows.Close()
+
+	scheduled := []ScheduledPrice{}
+	for rows.Next() {
+		var s ScheduledPrice
+		err := s.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan scheduled price: %v", err)
+		}
+		scheduled = append(scheduled, s)
+	}
+
+	return scheduled, nil
+}
+
+// CancelScheduledPrice cancels a pending price change of a product.
+func CancelScheduledPrice(db *sql.DB, productID, id int) (*ScheduledPrice, error) {
+	var s ScheduledPrice
+	query := `UPDATE scheduled_prices SET status = $3 WHERE product_id = $1 AND id = $2 AND status = $4
+			  RETURNING ` + scheduledPriceColumns
+	err := s.scan(db.QueryRow(query, productID, id, ScheduledPriceCancelled, ScheduledPricePending))
+	if err == sql.ErrNoRows {
+		var exists bool
+		err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM scheduled_prices WHERE product_id = $1 AND id = $2)", productID, id).Scan(&exists)
+		if err != nil {
+			return nil, fmt.Errorf("could not cancel scheduled price: %v", err)
+		}
+		if !exists {
+			return nil, ErrScheduledPriceNotFound
+		}
+		return nil, ErrScheduledPriceNotPending
+	} else if err != nil {
+		return nil, fmt.Errorf("could not cancel scheduled price: %v", err)
+	}
+	return &s, nil
+}
+
+// ApplyDuePrices applies up to limit pending price changes that are due at
+// now, oldest first, each in its own transaction. Changes locked by another
+// instance are skipped, so several schedulers can run at once. Changes of
+// products in the trash stay pending until the product is restored.
+func ApplyDuePrices(db *sql.DB, now time.Time, limit int) ([]AppliedPrice, error) {
+	applied := []AppliedPrice{}
+	skipped := 0
+	for len(applied)+skipped < limit {
+		change, err := applyNextDuePrice(db, now)
+		if errors.Is(err, errDuePriceTrashed) {
+			// Its changes are no longer selected, so the next call moves
+			// on to other products
+			skipped++
+			continue
+		} else if err != nil {
+			return applied, err
+		}
+		if change == nil {
+			break
+		}
+		applied = append(applied, *change)
+	}
+	return applied, nil
+}
+
+// errDuePriceTrashed is returned by applyNextDuePrice when the product of
+// the change it selected was moved to the trash before it could be locked.
+var errDuePriceTrashed = errors.New("product of scheduled price was deleted")
+
+// applyNextDuePrice applies the oldest due price change, if any.
+func applyNextDuePrice(db *sql.DB, now time.Time) (*AppliedPrice, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not apply scheduled price: %v", err)
+	}
+	defer tx.Rollback()
+
+	var change AppliedPrice
+	query := `SELECT ` + scheduledPriceColumns + ` FROM scheduled_prices
+			  WHERE status = $1 AND apply_at <= $2 AND product_id IN (SELECT id FROM products WHERE deleted_at IS NULL)
+			  ORDER BY apply_at, id LIMIT 1 FOR UPDATE SKIP LOCKED`
+	err = change.scan(tx.QueryRow(query, ScheduledPricePending, now))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not apply scheduled price: %v", err)
+	}
+
+	// The product may have been moved to the trash since
+	var old Price
+	query = `SELECT product_price, currency, user_id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	err = tx.QueryRow(query, change.ProductID).Scan(&old.Amount, &old.Currency, &change.UserID)
+	if err == sql.ErrNoRows {
+		return nil, errDuePriceTrashed
+	} else if err != nil {
+		return nil, fmt.Errorf("could not apply scheduled price: %v", err)
+	}
+
+	query = `WITH updated AS (
+			  UPDATE products SET product_price = $2, currency = $3, version = version + 1 WHERE id = $1 AND deleted_at IS NULL RETURNING id, currency
+			  )
+			  DELETE FROM product_prices USING updated
+			  WHERE product_prices.product_id = updated.id AND product_prices.currency = updated.currency`
+	_, err = tx.Exec(query, change.ProductID, change.Price.Amount, change.Price.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("could not apply scheduled price: %v", err)
+	}
+
+	err = recordPriceChange(tx, change.ProductID, old, change.Price, change.CreatedBy, PriceChangeScheduled, change.ID)
+	if err != nil {
+		return nil, err
+	}
+	err = recordRevision(tx, change.ProductID, change.CreatedBy, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `UPDATE scheduled_prices SET status = $2, applied_at = NOW() WHERE id = $1 RETURNING status, applied_at`
+	err = tx.QueryRow(query, change.ID, ScheduledPriceApplied).Scan(&change.Status, &change.AppliedAt)
+	if err != nil {
+		return nil, fmt.Errorf("could not apply scheduled price: %v", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not apply scheduled price: %v", err)
+	}
+	return &change, nil
+}
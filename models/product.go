@@ -2,23 +2,97 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
 )
 
+// ErrVersionConflict is returned when a product changed since it was read.
+var ErrVersionConflict = errors.New("product was changed by someone else")
+
 type Product struct {
-	ID                    int      `json:"id"`
-	UserID                int      `json:"user_id"`
-	ProductName           string   `json:"product_name"`
-	ProductDescription    string   `json:"product_description"`
-	ProductImages         []string `json:"product_images"`
-	ProductPrice          float64  `json:"product_price"`
-	CompressedProductImages []string `json:"compressed_product_images"`
+	ID                      int             `json:"id"`
+	UserID                  int             `json:"user_id"`
+	ProductName             string          `json:"product_name"`
+	ProductDescription      string          `json:"product_description"`
+	ProductImages           []string        `json:"product_images"`
+	ProductPrice            decimal.Decimal `json:"product_price"`
+	Currency                string          `json:"currency"`
+	CompressedProductImages []string        `json:"compressed_product_images"`
+	CreatedAt               time.Time       `json:"created_at"`
+	Status                  string          `json:"status"`
+	// SearchLanguage is the text search configuration the name and
+	// description are indexed with, DefaultSearchLanguage by default
+	SearchLanguage string `json:"search_language"`
+	// Version goes up with every change of the product, so that editors
+	// can tell whether it changed since they read it
+	Version int `json:"version"`
+	// PublishAt is when a draft is scheduled to be published, if it is
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// DeletedAt is set while the product is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Variants  []Variant  `json:"variants,omitempty"`
+	Prices    []Price    `json:"prices,omitempty"`
+	// ConvertedPrice is only set when a client asks for another currency
+	ConvertedPrice *ConvertedPrice `json:"converted_price,omitempty"`
+	// EffectivePrice is the price after the best running promotion, whose
+	// ID is PromotionID. Both are set by reads and listings.
+	EffectivePrice *decimal.Decimal `json:"effective_price,omitempty"`
+	PromotionID    int              `json:"promotion_id,omitempty"`
 }
 
-func (p *Product) Create(db *sql.DB) error {
-	query := `INSERT INTO products (user_id, product_name, product_description, product_images, product_price, compressed_product_images) 
-			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	err := db.QueryRow(query, p.UserID, p.ProductName, p.ProductDescription, pq.Array(p.ProductImages), p.ProductPrice, pq.Array(p.CompressedProductImages)).Scan(&p.ID)
+// Validate defaults and normalizes the currency and search language, and
+// checks that the price is valid in the currency.
+func (p *Product) Validate() error {
+	if p.SearchLanguage == "" {
+		p.SearchLanguage = DefaultSearchLanguage
+	}
+	if !searchLanguages[p.SearchLanguage] {
+		return ErrInvalidSearchLanguage
+	}
+	if p.Currency == "" {
+		p.Currency = DefaultCurrency
+	}
+	currency, err := NormalizeCurrency(p.Currency)
+	if err != nil {
+		return err
+	}
+	p.Currency = currency
+	return ValidatePrice(p.ProductPrice, p.Currency)
+}
+
+// Create saves a new product, as a draft unless it has a status, and its
+// first revision as made by actorID, which is zero when unknown.
+func (p *Product) Create(db *sql.DB, actorID int) error {
+	if p.Status == "" {
+		p.Status = ProductDraft
+	}
+	if p.SearchLanguage == "" {
+		p.SearchLanguage = DefaultSearchLanguage
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not create product: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO products (user_id, product_name, product_description, product_images, product_price, currency, compressed_product_images, status, search_language) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at, version`
+	err = tx.QueryRow(query, p.UserID, p.ProductName, p.ProductDescription, pq.Array(p.ProductImages), p.ProductPrice, p.Currency, pq.Array(p.CompressedProductImages), p.Status, p.SearchLanguage).Scan(&p.ID, &p.CreatedAt, &p.Version)
+	if err != nil {
+		return fmt.Errorf("could not create product: %v", err)
+	}
+
+	err = recordRevision(tx, p.ID, actorID, 0)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("could not create product: %v", err)
 	}
@@ -26,68 +100,179 @@ func (p *Product) Create(db *sql.DB) error {
 }
 
 func (p *Product) GetByID(db *sql.DB, id int) error {
-	query := `SELECT id, user_id, product_name, product_description, product_images, product_price, compressed_product_images 
-			  FROM products WHERE id = $1`
+	query := `SELECT id, user_id, product_name, product_description, product_images, product_price, currency, compressed_product_images, created_at, status, publish_at, version, search_language
+			  FROM products WHERE id = $1 AND deleted_at IS NULL`
 	row := db.QueryRow(query, id)
-	err := row.Scan(&p.ID, &p.UserID, &p.ProductName, &p.ProductDescription, pq.Array(&p.ProductImages), &p.ProductPrice, pq.Array(&p.CompressedProductImages))
+	err := row.Scan(&p.ID, &p.UserID, &p.ProductName, &p.ProductDescription, pq.Array(&p.ProductImages), &p.ProductPrice, &p.Currency, pq.Array(&p.CompressedProductImages), &p.CreatedAt, &p.Status, &p.PublishAt, &p.Version, &p.SearchLanguage)
+	if err != nil {
+		return fmt.Errorf("could not get product by id: %w", err)
+	}
+
+	p.Variants, err = GetProductVariants(db, p.ID)
+	if err != nil {
+		return fmt.Errorf("could not get product by id: %w", err)
+	}
+	p.Prices, err = GetProductPrices(db, p.ID)
 	if err != nil {
-		return fmt.Errorf("could not get product by id: %v", err)
+		return fmt.Errorf("could not get product by id: %w", err)
 	}
 	return nil
 }
 
-func (p *Product) Update(db *sql.DB) error {
-	query := `UPDATE products SET user_id = $1, product_name = $2, product_description = $3, product_images = $4, product_price = $5, compressed_product_images = $6 
-			  WHERE id = $7`
-	_, err := db.Exec(query, p.UserID, p.ProductName, p.ProductDescription, pq.Array(p.ProductImages), p.ProductPrice, pq.Array(p.CompressedProductImages), p.ID)
+// Update saves the product's fields. Variants and prices in other currencies
+// are managed separately, but variants lose the images that the product no
+// longer has, and a price in the product's new currency is dropped. The
+// change is saved as a revision, and a change of price in the price
+// history, as made by actorID, which is zero when unknown. It fails with
+// ErrVersionConflict unless the product is still at p's Version, which is
+// then incremented.
+func (p *Product) Update(db *sql.DB, actorID int) error {
+	return p.update(db, actorID, 0)
+}
+
+// update is Update, recording that it reverts to revertedFrom if set.
+func (p *Product) update(db *sql.DB, actorID, revertedFrom int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not update product: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the row so that the recorded old price is the one replaced
+	var old Price
+	var version int
+	query := "SELECT product_price, currency, version FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+	err = tx.QueryRow(query, p.ID).Scan(&old.Amount, &old.Currency, &version)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not update product: %v", err)
+	}
+	if version != p.Version {
+		return ErrVersionConflict
+	}
+
+	query = `WITH updated AS (
+			  UPDATE products SET user_id = $1, product_name = $2, product_description = $3, product_images = $4, product_price = $5, currency = $6, compressed_product_images = $7, search_language = $8, version = version + 1 
+			  WHERE id = $9 RETURNING id, product_images, currency
+			  ), dropped AS (
+			  DELETE FROM product_prices USING updated
+			  WHERE product_prices.product_id = updated.id AND product_prices.currency = updated.currency
+			  )
+			  UPDATE product_variants SET images = ARRAY(SELECT image FROM unnest(product_variants.images) AS image WHERE image = ANY(updated.product_images))
+			  FROM updated WHERE product_variants.product_id = updated.id`
+	_, err = tx.Exec(query, p.UserID, p.ProductName, p.ProductDescription, pq.Array(p.ProductImages), p.ProductPrice, p.Currency, pq.Array(p.CompressedProductImages), p.SearchLanguage, p.ID)
 	if err != nil {
 		return fmt.Errorf("could not update product: %v", err)
 	}
+
+	err = recordPriceChange(tx, p.ID, old, Price{Amount: p.ProductPrice, Currency: p.Currency}, actorID, PriceChangeManual, 0)
+	if err != nil {
+		return err
+	}
+	err = recordRevision(tx, p.ID, actorID, revertedFrom)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("could not update product: %v", err)
+	}
+	p.Version++
 	return nil
 }
 
+// Delete moves the product to the trash, from which it can be restored
+// until PurgeDeletedProducts removes it for good. Like Update, it fails with
+// ErrVersionConflict unless the product is still at p's Version.
 func (p *Product) Delete(db *sql.DB) error {
-	query := `DELETE FROM products WHERE id = $1`
-	_, err := db.Exec(query, p.ID)
-	if err != nil {
+	query := `UPDATE products SET deleted_at = NOW(), version = version + 1
+			  WHERE id = $1 AND deleted_at IS NULL AND version = $2 RETURNING version`
+	err := db.QueryRow(query, p.ID, p.Version).Scan(&p.Version)
+	if err == sql.ErrNoRows {
+		var exists bool
+		err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", p.ID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("could not delete product: %v", err)
+		}
+		if !exists {
+			return ErrProductNotFound
+		}
+		return ErrVersionConflict
+	} else if err != nil {
 		return fmt.Errorf("could not delete product: %v", err)
 	}
 	return nil
 }
 
-func GetAllProducts(db *sql.DB, userID int, minPrice, maxPrice float64, productName string) ([]Product, error) {
-	query := `SELECT id, user_id, product_name, product_description, product_images, product_price, compressed_product_images 
-			  FROM products WHERE user_id = $1`
-	args := []interface{}{userID}
+// GetAllProducts returns one page of the products matching the filter.
+func GetAllProducts(db *sql.DB, filter ProductFilter, page PageOptions) (*ProductPage, error) {
+	if page.Sort.Field == "" {
+		page.Sort = DefaultProductSort
+	}
+
+	b := &QueryBuilder{}
+	b.Where("deleted_at IS NULL")
+	filter.Apply(b)
 
-	if minPrice > 0 {
-		query += " AND product_price >= $2"
-		args = append(args, minPrice)
+	result := &ProductPage{Products: []Product{}}
+	if page.IncludeTotal {
+		var total int
+		err := db.QueryRow(`SELECT COUNT(*) FROM `+productsWithPromotion+b.WhereClause(), b.Args()...).Scan(&total)
+		if err != nil {
+			return nil, fmt.Errorf("could not count products: %v", err)
+		}
+		result.TotalCount = &total
 	}
-	if maxPrice > 0 {
-		query += " AND product_price <= $3"
-		args = append(args, maxPrice)
+
+	if page.Facets != nil {
+		err := page.Facets.Validate()
+		if err != nil {
+			return nil, err
+		}
+		result.Facets, err = queryFacets(db, "", productsWithPromotion, b, *page.Facets)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if productName != "" {
-		query += " AND product_name ILIKE $4"
-		args = append(args, "%"+productName+"%")
+
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Sort, page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		page.Sort.applyAfter(b, cursor)
 	}
 
-	rows, err := db.Query(query, args...)
+	// Fetch one extra row to learn whether there is a next page
+	limit := page.limit()
+	query := `SELECT id, user_id, product_name, product_description, product_images, product_price, currency, compressed_product_images, created_at, status, publish_at, version, search_language, ` +
+		effectivePrice + `, COALESCE(promo.promotion_id, 0)
+			  FROM ` + productsWithPromotion + b.WhereClause() + page.Sort.orderBy() + fmt.Sprintf(" LIMIT %d", limit+1)
+
+	rows, err := db.Query(query, b.Args()...)
 	if err != nil {
 		return nil, fmt.Errorf("could not get all products: %v", err)
 	}
 	defer rows.Close()
 
-	var products []Product
 	for rows.Next() {
 		var p Product
-		err := rows.Scan(&p.ID, &p.UserID, &p.ProductName, &p.ProductDescription, pq.Array(&p.ProductImages), &p.ProductPrice, pq.Array(&p.CompressedProductImages))
+		var price decimal.Decimal
+		err := rows.Scan(&p.ID, &p.UserID, &p.ProductName, &p.ProductDescription, pq.Array(&p.ProductImages), &p.ProductPrice, &p.Currency, pq.Array(&p.CompressedProductImages), &p.CreatedAt,
+			&p.Status, &p.PublishAt, &p.Version, &p.SearchLanguage, &price, &p.PromotionID)
 		if err != nil {
 			return nil, fmt.Errorf("could not scan product: %v", err)
 		}
-		products = append(products, p)
+		p.EffectivePrice = &price
+		result.Products = append(result.Products, p)
+	}
+
+	if len(result.Products) > limit {
+		result.Products = result.Products[:limit]
+		result.NextCursor = encodeCursor(page.Sort, result.Products[limit-1])
 	}
 
-	return products, nil
+	return result, nil
 }
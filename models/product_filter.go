@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// ProductFilter narrows a product listing. Zero values leave the
+// corresponding filter out.
+type ProductFilter struct {
+	UserID        int
+	MinPrice      decimal.Decimal
+	MaxPrice      decimal.Decimal
+	ProductName   string
+	Description   string
+	HasImages     *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	IDs           []int
+	// CategoryID matches products in the category or any of its descendants.
+	CategoryID int
+	// Tags matches products with any of the tags, or with all of them if
+	// AllTags is set.
+	Tags    []string
+	AllTags bool
+	// LowStock matches products with an item at or below its low stock
+	// threshold. Items that were never stocked are not tracked.
+	LowStock bool
+	// Status matches products in this status.
+	Status string
+}
+
+// Apply adds the filter's conditions to b. They refer to
+// productsWithPromotion, as the price range applies to the effective price.
+func (f ProductFilter) Apply(b *QueryBuilder) {
+	if f.UserID != 0 {
+		b.Where("user_id = ?", f.UserID)
+	}
+	if f.MinPrice.IsPositive() {
+		b.Where(effectivePrice+" >= ?", f.MinPrice)
+	}
+	if f.MaxPrice.IsPositive() {
+		b.Where(effectivePrice+" <= ?", f.MaxPrice)
+	}
+	if f.ProductName != "" {
+		b.Where("product_name ILIKE ?", "%"+f.ProductName+"%")
+	}
+	if f.Description != "" {
+		b.Where("product_description ILIKE ?", "%"+f.Description+"%")
+	}
+	if f.HasImages != nil {
+		if *f.HasImages {
+			b.Where("cardinality(product_images) > 0")
+		} else {
+			b.Where("COALESCE(cardinality(product_images), 0) = 0")
+		}
+	}
+	if !f.CreatedAfter.IsZero() {
+		b.Where("created_at >= ?", f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		b.Where("created_at < ?", f.CreatedBefore)
+	}
+	if f.IDs != nil {
+		b.Where("id = ANY(?)", pq.Array(f.IDs))
+	}
+	if f.CategoryID != 0 {
+		b.Where("id IN (SELECT product_id FROM product_categories WHERE category_id IN ("+categorySubtree+"))", f.CategoryID)
+	}
+	if len(f.Tags) > 0 {
+		if f.AllTags {
+			b.Where("id IN (SELECT product_id FROM product_tags WHERE tag = ANY(?) GROUP BY product_id HAVING COUNT(*) = ?)", pq.Array(f.Tags), len(f.Tags))
+		} else {
+			b.Where("id IN (SELECT product_id FROM product_tags WHERE tag = ANY(?))", pq.Array(f.Tags))
+		}
+	}
+	if f.Status != "" {
+		b.Where("status = ?", f.Status)
+	}
+	if f.LowStock {
+		b.Where("id IN (SELECT product_id FROM inventory WHERE on_hand - reserved <= low_stock_threshold)")
+	}
+}
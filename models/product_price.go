@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPriceNotFound     = errors.New("price not found")
+	ErrBaseCurrencyPrice = errors.New("price is in the product's own currency")
+)
+
+// GetProductPrices returns the prices of a product in currencies other than
+// its own, ordered by currency.
+func GetProductPrices(db *sql.DB, productID int) ([]Price, error) {
+	rows, err := db.Query("SELECT currency, amount FROM product_prices WHERE product_id = $1 ORDER BY currency", productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get product prices: %v", err)
+	}
+	defer rows.Close()
+
+	prices := []Price{}
+	for rows.Next() {
+		var p Price
+		err := rows.Scan(&p.Currency, &p.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan price: %v", err)
+		}
+		prices = append(prices, p)
+	}
+
+	return prices, nil
+}
+
+// SetProductPrice sets the price of a product in a currency other than its
+// own. The price must have been validated.
+func SetProductPrice(db *sql.DB, product Product, price Price) error {
+	if price.Currency == product.Currency {
+		return ErrBaseCurrencyPrice
+	}
+
+	query := `INSERT INTO product_prices (product_id, currency, amount) VALUES ($1, $2, $3)
+			  ON CONFLICT (product_id, currency) DO UPDATE SET amount = EXCLUDED.amount`
+	_, err := db.Exec(query, product.ID, price.Currency, price.Amount)
+	if err != nil {
+		return fmt.Errorf("could not set product price: %v", err)
+	}
+	return nil
+}
+
+// DeleteProductPrice removes the price of a product in a currency.
+func DeleteProductPrice(db *sql.DB, productID int, currency string) error {
+	res, err := db.Exec("DELETE FROM product_prices WHERE product_id = $1 AND currency = $2", productID, currency)
+	if err != nil {
+		return fmt.Errorf("could not delete product price: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete product price: %v", err)
+	}
+	if n == 0 {
+		return ErrPriceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Product statuses. Only published products are shown to anyone but their
+// owner.
+const (
+	ProductDraft     = "draft"
+	ProductPublished = "published"
+	ProductArchived  = "archived"
+)
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInvalidStatus     = errors.New("invalid product status")
+	ErrInvalidTransition = errors.New("product status can't change this way")
+	ErrPublishAtInPast   = errors.New("publish time must be in the future")
+)
+
+// statusTransitions lists the statuses a product may move to from each
+// status. Moving a draft to draft cancels its scheduled publication.
+var statusTransitions = map[string][]string{
+	ProductDraft:     {ProductDraft, ProductPublished, ProductArchived},
+	ProductPublished: {ProductDraft, ProductArchived},
+	ProductArchived:  {ProductDraft},
+}
+
+// ValidProductStatus reports whether status is a product status.
+func ValidProductStatus(status string) bool {
+	_, ok := statusTransitions[status]
+	return ok
+}
+
+// CanTransition reports whether a product may move from one status to
+// another.
+func CanTransition(from, to string) bool {
+	for _, status := range statusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionsTo returns the statuses from which a product may move to
+// status.
+func transitionsTo(status string) []string {
+	from := []string{}
+	for s := range statusTransitions {
+		if CanTransition(s, status) {
+			from = append(from, s)
+		}
+	}
+	return from
+}
+
+// SetStatus moves the product to status on behalf of its owner, userID, and
+// clears any scheduled publication. Publishing with a publishAt time instead
+// keeps a draft unpublished until then. It fails with ErrNotProductOwner if
+// the product belongs to someone else, and with ErrInvalidTransition if the
+// product's current status doesn't allow the change. p's UserID, Status and
+// PublishAt are set from the updated product, as is its Version.
+func (p *Product) SetStatus(db *sql.DB, status string, publishAt *time.Time, userID int) error {
+	if !ValidProductStatus(status) {
+		return ErrInvalidStatus
+	}
+
+	from := transitionsTo(status)
+	if publishAt != nil {
+		if status != ProductPublished {
+			return ErrInvalidTransition
+		}
+		if !publishAt.After(time.Now()) {
+			return ErrPublishAtInPast
+		}
+		status, from = ProductDraft, []string{ProductDraft}
+	}
+
+	query := `UPDATE products SET status = $2, publish_at = $3, version = version + 1
+			  WHERE id = $1 AND status = ANY($4) AND deleted_at IS NULL AND user_id = $5
+			  RETURNING user_id, status, publish_at, version`
+	err := db.QueryRow(query, p.ID, status, publishAt, pq.Array(from), userID).Scan(&p.UserID, &p.Status, &p.PublishAt, &p.Version)
+	if err == sql.ErrNoRows {
+		var owner int
+		err = db.QueryRow("SELECT user_id FROM products WHERE id = $1 AND deleted_at IS NULL", p.ID).Scan(&owner)
+		if err == sql.ErrNoRows {
+			return ErrProductNotFound
+		} else if err != nil {
+			return fmt.Errorf("could not set product status: %v", err)
+		}
+		if owner != userID {
+			return ErrNotProductOwner
+		}
+		return ErrInvalidTransition
+	} else if err != nil {
+		return fmt.Errorf("could not set product status: %v", err)
+	}
+	return nil
+}
+
+// PublishDueProducts publishes up to limit drafts whose publication was
+// scheduled at or before now, and returns them with only their ID and
+// UserID set. Products locked by another instance are skipped.
+func PublishDueProducts(db *sql.DB, now time.Time, limit int) ([]Product, error) {
+	query := `UPDATE products SET status = $1, publish_at = NULL, version = version + 1
+			  WHERE id IN (
+			  SELECT id FROM products WHERE status = $2 AND publish_at <= $3 AND deleted_at IS NULL
+			  ORDER BY publish_at, id LIMIT $4 FOR UPDATE SKIP LOCKED
+			  ) RETURNING id, user_id`
+	rows, err := db.Query(query, ProductPublished, ProductDraft, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not publish scheduled products: %v", err)
+	}
+	defer rows.Close()
+
+	published := []Product{}
+	for rows.Next() {
+		var p Product
+		err := rows.Scan(&p.ID, &p.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan published product: %v", err)
+		}
+		published = append(published, p)
+	}
+	return published, nil
+}
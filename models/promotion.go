@@ -0,0 +1,386 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// Discount types of a promotion.
+const (
+	// DiscountPercentage takes Amount percent off the price.
+	DiscountPercentage = "percentage"
+	// DiscountFixed takes Amount off the price of products in the
+	// promotion's currency.
+	DiscountFixed = "fixed"
+)
+
+var (
+	ErrPromotionNotFound = errors.New("promotion not found")
+	ErrInvalidPromotion  = errors.New("invalid promotion")
+)
+
+// Promotion discounts the products it targets between StartsAt and EndsAt,
+// or indefinitely if EndsAt is unset. It targets the products listed in
+// ProductIDs, those in any of CategoryIDs or their descendants, and those
+// carrying any of Tags.
+type Promotion struct {
+	ID           int             `json:"id"`
+	Name         string          `json:"name"`
+	DiscountType string          `json:"discount_type"`
+	Amount       decimal.Decimal `json:"amount"`
+	Currency     string          `json:"currency,omitempty"`
+	StartsAt     time.Time       `json:"starts_at"`
+	EndsAt       *time.Time      `json:"ends_at"`
+	ProductIDs   []int           `json:"product_ids"`
+	CategoryIDs  []int           `json:"category_ids"`
+	Tags         []string        `json:"tags"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// Validate checks the discount, the dates and the targets, normalizing the
+// currency and tags. StartsAt defaults to now.
+func (p *Promotion) Validate() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("%w: missing name", ErrInvalidPromotion)
+	}
+
+	switch p.DiscountType {
+	case DiscountPercentage:
+		if !p.Amount.IsPositive() || p.Amount.GreaterThan(decimal.NewFromInt(100)) {
+			return fmt.Errorf("%w: percentage must be above 0 and at most 100", ErrInvalidPromotion)
+		}
+		p.Currency = ""
+	case DiscountFixed:
+		price := Price{Currency: p.Currency, Amount: p.Amount}
+		err := price.Validate()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromotion, err)
+		}
+		if !p.Amount.IsPositive() {
+			return fmt.Errorf("%w: amount must be positive", ErrInvalidPromotion)
+		}
+		p.Currency = price.Currency
+	default:
+		return fmt.Errorf("%w: unknown discount type %q", ErrInvalidPromotion, p.DiscountType)
+	}
+
+	if p.StartsAt.IsZero() {
+		p.StartsAt = time.Now()
+	}
+	if p.EndsAt != nil && !p.EndsAt.After(p.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 && len(p.Tags) == 0 {
+		return fmt.Errorf("%w: no products, categories or tags targeted", ErrInvalidPromotion)
+	}
+	if p.ProductIDs == nil {
+		p.ProductIDs = []int{}
+	}
+	if p.CategoryIDs == nil {
+		p.CategoryIDs = []int{}
+	}
+	tags, err := NormalizeTags(p.Tags)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPromotion, err)
+	}
+	p.Tags = tags
+	return nil
+}
+
+// Active reports whether the promotion runs at t.
+func (p Promotion) Active(t time.Time) bool {
+	return !t.Before(p.StartsAt) && (p.EndsAt == nil || t.Before(*p.EndsAt))
+}
+
+// Apply returns price after the discount, rounded half up to the currency's
+// decimal places and never below zero. ok is false if the discount doesn't
+// apply to prices in currency. It must agree with discountedPrice.
+func (p Promotion) Apply(price decimal.Decimal, currency string) (discounted decimal.Decimal, ok bool) {
+	scale, err := CurrencyScale(currency)
+	if err != nil {
+		return price, false
+	}
+
+	switch p.DiscountType {
+	case DiscountPercentage:
+		hundred := decimal.NewFromInt(100)
+		discounted = price.Mul(hundred.Sub(p.Amount)).Div(hundred)
+	case DiscountFixed:
+		if p.Currency != currency {
+			return price, false
+		}
+		discounted = price.Sub(p.Amount)
+	default:
+		return price, false
+	}
+
+	discounted = RoundHalfUp.Round(discounted, scale)
+	if discounted.IsNegative() {
+		discounted = decimal.Zero
+	}
+	return discounted, true
+}
+
+// discountedPrice is the SQL version of Promotion.Apply for the promotion
+// and product rows in scope.
+var discountedPrice = `GREATEST(round(CASE promotions.discount_type
+		WHEN 'percentage' THEN products.product_price * (100 - promotions.amount) / 100
+		ELSE products.product_price - promotions.amount
+	END, ` + currencyScaleSQL("products.currency") + `), 0)`
+
+// currencyScaleSQL returns an SQL expression giving the number of decimal
+// places of the currency in column.
+func currencyScaleSQL(column string) string {
+	currencies := make([]string, 0, len(currencyScales))
+	for currency := range currencyScales {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CASE %s", column)
+	for _, currency := range currencies {
+		fmt.Fprintf(&b, " WHEN '%s' THEN %d", currency, currencyScales[currency])
+	}
+	b.WriteString(" ELSE 2 END")
+	return b.String()
+}
+
+// productCategoryAncestors selects the categories of the product in scope
+// and all of their ancestors.
+const productCategoryAncestors = `WITH RECURSIVE ancestors AS (
+		SELECT category_id AS id FROM product_categories WHERE product_categories.product_id = products.id
+		UNION
+		SELECT categories.parent_id FROM categories JOIN ancestors ON categories.id = ancestors.id
+		WHERE categories.parent_id IS NOT NULL
+	) SELECT id FROM ancestors`
+
+// promotionApplies holds when the promotion in scope discounts the product
+// in scope while it runs.
+const promotionApplies = `(promotions.discount_type = 'percentage' OR promotions.currency = products.currency)
+	AND (products.id = ANY(promotions.product_ids)
+		OR promotions.tags && ARRAY(SELECT tag FROM product_tags WHERE product_tags.product_id = products.id)
+		OR promotions.category_ids && ARRAY(` + productCategoryAncestors + `))`
+
+// bestPromotion selects the running promotion that gives the product in
+// scope its lowest price, as promotion_id and price. It is joined LATERAL
+// to products.
+var bestPromotion = `SELECT promotions.id AS promotion_id, ` + discountedPrice + ` AS price
+	FROM promotions
+	WHERE promotions.starts_at <= NOW() AND (promotions.ends_at IS NULL OR promotions.ends_at > NOW())
+	AND ` + promotionApplies + `
+	ORDER BY 2, promotions.id LIMIT 1`
+
+// productsWithPromotion joins products with their best promotion as "promo".
+// Listings select from it so that they can filter and sort by
+// effectivePrice.
+var productsWithPromotion = `products LEFT JOIN LATERAL (` + bestPromotion + `) AS promo ON true`
+
+// effectivePrice is a product's price after its best promotion, in
+// queries selecting from productsWithPromotion.
+const effectivePrice = `COALESCE(promo.price, products.product_price)`
+
+const promotionColumns = `promotions.id, promotions.name, promotions.discount_type, promotions.amount, promotions.currency,
+	promotions.starts_at, promotions.ends_at, promotions.product_ids, promotions.category_ids, promotions.tags, promotions.created_at`
+
+func (p *Promotion) scan(row scanner, dest ...interface{}) error {
+	var currency sql.NullString
+	var productIDs, categoryIDs pq.Int64Array
+	dest = append(dest, &p.ID, &p.Name, &p.DiscountType, &p.Amount, &currency,
+		&p.StartsAt, &p.EndsAt, &productIDs, &categoryIDs, pq.Array(&p.Tags), &p.CreatedAt)
+	err := row.Scan(dest...)
+	if err != nil {
+		return err
+	}
+
+	p.Currency = currency.String
+	p.ProductIDs = intsFromInt64s(productIDs)
+	p.CategoryIDs = intsFromInt64s(categoryIDs)
+	if p.Tags == nil {
+		p.Tags = []string{}
+	}
+	return nil
+}
+
+func intsFromInt64s(values []int64) []int {
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = int(v)
+	}
+	return ints
+}
+
+func (p *Promotion) Create(db *sql.DB) error {
+	query := `INSERT INTO promotions (name, discount_type, amount, currency, starts_at, ends_at, product_ids, category_ids, tags)
+			  VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9) RETURNING id, created_at`
+	err := db.QueryRow(query, p.Name, p.DiscountType, p.Amount, p.Currency, p.StartsAt, p.EndsAt,
+		pq.Array(p.ProductIDs), pq.Array(p.CategoryIDs), pq.Array(p.Tags)).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("could not create promotion: %v", err)
+	}
+	return nil
+}
+
+func (p *Promotion) GetByID(db *sql.DB, id int) error {
+	err := p.scan(db.QueryRow(`SELECT `+promotionColumns+` FROM promotions WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return ErrPromotionNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not get promotion by id: %v", err)
+	}
+	return nil
+}
+
+func (p *Promotion) Update(db *sql.DB) error {
+	query := `UPDATE promotions SET name = $2, discount_type = $3, amount = $4, currency = NULLIF($5, ''), starts_at = $6, ends_at = $7,
+			  product_ids = $8, category_ids = $9, tags = $10
+			  WHERE id = $1 RETURNING created_at`
+	err := db.QueryRow(query, p.ID, p.Name, p.DiscountType, p.Amount, p.Currency, p.StartsAt, p.EndsAt,
+		pq.Array(p.ProductIDs), pq.Array(p.CategoryIDs), pq.Array(p.Tags)).Scan(&p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrPromotionNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not update promotion: %v", err)
+	}
+	return nil
+}
+
+func (p *Promotion) Delete(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM promotions WHERE id = $1", p.ID)
+	if err != nil {
+		return fmt.Errorf("could not delete promotion: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete promotion: %v", err)
+	}
+	if n == 0 {
+		return ErrPromotionNotFound
+	}
+	return nil
+}
+
+// GetOwners returns the IDs of the users owning the products the promotion
+// applies to, whether or not it is running. Their listings are filtered and
+// sorted by effective price, so they change with it.
+func (p *Promotion) GetOwners(db *sql.DB) ([]int, error) {
+	query := `SELECT DISTINCT products.user_id FROM products JOIN promotions ON promotions.id = $1
+			  WHERE products.deleted_at IS NULL AND ` + promotionApplies
+	rows, err := db.Query(query, p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get promotion owners: %v", err)
+	}
+	defer rows.Close()
+
+	owners := []int{}
+	for rows.Next() {
+		var userID int
+		err := rows.Scan(&userID)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan promotion owner: %v", err)
+		}
+		owners = append(owners, userID)
+	}
+	return owners, rows.Err()
+}
+
+// GetPromotions returns every promotion, or only those running now if
+// activeOnly is set, latest start first.
+func GetPromotions(db *sql.DB, activeOnly bool) ([]Promotion, error) {
+	query := `SELECT ` + promotionColumns + ` FROM promotions`
+	if activeOnly {
+		query += ` WHERE starts_at <= NOW() AND (ends_at IS NULL OR ends_at > NOW())`
+	}
+	query += ` ORDER BY starts_at DESC, id DESC`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("could not get promotions: %v", err)
+	}
+	defer rows.Close()
+
+	promotions := []Promotion{}
+	for rows.Next() {
+		var p Promotion
+		err := p.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan promotion: %v", err)
+		}
+		promotions = append(promotions, p)
+	}
+	return promotions, nil
+}
+
+// ApplyPromotions sets the effective price of the products and their
+// variants from each product's best running promotion. Variants get the
+// product's promotion. Products are updated in place but their variants are
+// copied, so products read from the cache can be passed.
+func ApplyPromotions(db *sql.DB, products []*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+
+	query := `SELECT products.id, ` + promotionColumns + ` FROM products
+			  JOIN LATERAL (` + bestPromotion + `) AS promo ON true
+			  JOIN promotions ON promotions.id = promo.promotion_id
+			  WHERE products.id = ANY($1)`
+	rows, err := db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("could not get promotions: %v", err)
+	}
+	defer rows.Close()
+
+	promotions := make(map[int]Promotion, len(products))
+	for rows.Next() {
+		var productID int
+		var promotion Promotion
+		err := promotion.scan(rows, &productID)
+		if err != nil {
+			return fmt.Errorf("could not scan promotion: %v", err)
+		}
+		promotions[productID] = promotion
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("could not get promotions: %v", err)
+	}
+
+	for _, p := range products {
+		promotion, found := promotions[p.ID]
+		p.EffectivePrice, p.PromotionID = applyPromotion(promotion, found, p.ProductPrice, p.Currency)
+
+		variants := make([]Variant, len(p.Variants))
+		for i, v := range p.Variants {
+			v.EffectivePrice, v.PromotionID = applyPromotion(promotion, found, v.Price, p.Currency)
+			variants[i] = v
+		}
+		if p.Variants != nil {
+			p.Variants = variants
+		}
+	}
+	return nil
+}
+
+func applyPromotion(promotion Promotion, found bool, price decimal.Decimal, currency string) (*decimal.Decimal, int) {
+	if found {
+		if discounted, ok := promotion.Apply(price, currency); ok {
+			return &discounted, promotion.ID
+		}
+	}
+	return &price, 0
+}
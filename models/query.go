@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryBuilder collects the conditions of a WHERE clause together with their
+// arguments, numbering placeholders in the order the arguments are added.
+type QueryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Arg adds an argument and returns its placeholder, e.g. "$3".
+func (b *QueryBuilder) Arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// Where adds a condition ANDed with the others. Each "?" in cond is replaced
+// by the placeholder of the corresponding value, so conditions must not
+// contain a literal question mark.
+func (b *QueryBuilder) Where(cond string, values ...interface{}) *QueryBuilder {
+	parts := strings.Split(cond, "?")
+	if len(parts) != len(values)+1 {
+		panic(fmt.Sprintf("query builder: %q expects %d values, got %d", cond, len(parts)-1, len(values)))
+	}
+
+	var sb strings.Builder
+	for i, value := range values {
+		sb.WriteString(parts[i])
+		sb.WriteString(b.Arg(value))
+	}
+	sb.WriteString(parts[len(values)])
+
+	b.conditions = append(b.conditions, sb.String())
+	return b
+}
+
+// WhereClause returns " WHERE ..." or an empty string if there are no
+// conditions.
+func (b *QueryBuilder) WhereClause() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// Args returns the arguments in placeholder order.
+func (b *QueryBuilder) Args() []interface{} {
+	return b.args
+}
+
+// Clone returns a copy of b that can be extended without affecting b.
+func (b *QueryBuilder) Clone() *QueryBuilder {
+	return &QueryBuilder{
+		conditions: append([]string(nil), b.conditions...),
+		args:       append([]interface{}(nil), b.args...),
+	}
+}
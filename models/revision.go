@@ -0,0 +1,188 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// ProductSnapshot holds the editable fields of a product as they were at a
+// revision.
+type ProductSnapshot struct {
+	UserID             int             `json:"user_id"`
+	ProductName        string          `json:"product_name"`
+	ProductDescription string          `json:"product_description"`
+	ProductImages      []string        `json:"product_images"`
+	ProductPrice       decimal.Decimal `json:"product_price"`
+	Currency           string          `json:"currency"`
+}
+
+// productSnapshotSQL builds a ProductSnapshot from the products row in
+// scope. Prices are kept as text so that they stay exact.
+const productSnapshotSQL = `jsonb_build_object(
+		'user_id', user_id, 'product_name', product_name, 'product_description', COALESCE(product_description, ''),
+		'product_images', COALESCE(product_images, '{}'), 'product_price', product_price::text, 'currency', currency)`
+
+// Revision is a numbered snapshot of a product, saved whenever it is
+// created or changed. ChangedBy is zero when unknown, and RevertedFrom is
+// set when the change reverted the product to that earlier revision.
+type Revision struct {
+	ProductID    int             `json:"product_id"`
+	Revision     int             `json:"revision"`
+	Snapshot     ProductSnapshot `json:"snapshot"`
+	ChangedBy    int             `json:"changed_by,omitempty"`
+	RevertedFrom int             `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// FieldChange is a field that differs between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// RevisionDiff lists the fields that changed from one revision to another.
+type RevisionDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// recordRevision saves the current state of a product as its next
+// revision. The product row must be locked or new, so that revision numbers
+// are taken one at a time.
+func recordRevision(tx *sql.Tx, productID, actorID, revertedFrom int) error {
+	query := `INSERT INTO product_revisions (product_id, revision, snapshot, changed_by, reverted_from)
+			  SELECT id, (SELECT COALESCE(MAX(revision), 0) + 1 FROM product_revisions WHERE product_id = $1),
+			  ` + productSnapshotSQL + `, NULLIF($2, 0), NULLIF($3, 0)
+			  FROM products WHERE id = $1`
+	_, err := tx.Exec(query, productID, actorID, revertedFrom)
+	if err != nil {
+		return fmt.Errorf("could not record revision: %v", err)
+	}
+	return nil
+}
+
+const revisionColumns = `product_id, revision, snapshot, COALESCE(changed_by, 0), COALESCE(reverted_from, 0), created_at`
+
+func (r *Revision) scan(row scanner) error {
+	var snapshot []byte
+	err := row.Scan(&r.ProductID, &r.Revision, &snapshot, &r.ChangedBy, &r.RevertedFrom, &r.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(snapshot, &r.Snapshot)
+}
+
+// GetProductRevisions returns up to limit revisions of a product, latest
+// first.
+func GetProductRevisions(db *sql.DB, productID, limit int) ([]Revision, error) {
+	limit = PageOptions{Limit: limit}.limit()
+	query := `SELECT ` + revisionColumns + ` FROM product_revisions
+			  WHERE product_id = $1 ORDER BY revision DESC LIMIT $2`
+	rows, err := db.Query(query, productID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revisions: %v", err)
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var r Revision
+		err := r.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan revision: %v", err)
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+func GetProductRevision(db *sql.DB, productID, revision int) (*Revision, error) {
+	var r Revision
+	query := `SELECT ` + revisionColumns + ` FROM product_revisions WHERE product_id = $1 AND revision = $2`
+	err := r.scan(db.QueryRow(query, productID, revision))
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get revision: %v", err)
+	}
+	return &r, nil
+}
+
+// DiffRevisions compares two revisions of a product.
+func DiffRevisions(db *sql.DB, productID, from, to int) (*RevisionDiff, error) {
+	a, err := GetProductRevision(db, productID, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := GetProductRevision(db, productID, to)
+	if err != nil {
+		return nil, err
+	}
+	return &RevisionDiff{From: from, To: to, Changes: DiffSnapshots(a.Snapshot, b.Snapshot)}, nil
+}
+
+// DiffSnapshots lists the fields that differ from a to b, in the order
+// they appear in a product.
+func DiffSnapshots(a, b ProductSnapshot) []FieldChange {
+	changes := []FieldChange{}
+	if a.UserID != b.UserID {
+		changes = append(changes, FieldChange{"user_id", a.UserID, b.UserID})
+	}
+	if a.ProductName != b.ProductName {
+		changes = append(changes, FieldChange{"product_name", a.ProductName, b.ProductName})
+	}
+	if a.ProductDescription != b.ProductDescription {
+		changes = append(changes, FieldChange{"product_description", a.ProductDescription, b.ProductDescription})
+	}
+	if !equalStrings(a.ProductImages, b.ProductImages) {
+		changes = append(changes, FieldChange{"product_images", a.ProductImages, b.ProductImages})
+	}
+	if !a.ProductPrice.Equal(b.ProductPrice) {
+		changes = append(changes, FieldChange{"product_price", a.ProductPrice, b.ProductPrice})
+	}
+	if a.Currency != b.Currency {
+		changes = append(changes, FieldChange{"currency", a.Currency, b.Currency})
+	}
+	return changes
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Revert restores the fields saved in one of the product's revisions and
+// records the result as a new revision. p must have been read with GetByID;
+// fields that revisions don't hold, such as the status and compressed
+// images, are kept. Like Update, it fails with ErrVersionConflict if the
+// product changed since p was read.
+func (p *Product) Revert(db *sql.DB, revision, actorID int) error {
+	r, err := GetProductRevision(db, p.ID, revision)
+	if err != nil {
+		return err
+	}
+
+	p.UserID = r.Snapshot.UserID
+	p.ProductName = r.Snapshot.ProductName
+	p.ProductDescription = r.Snapshot.ProductDescription
+	p.ProductImages = r.Snapshot.ProductImages
+	p.ProductPrice = r.Snapshot.ProductPrice
+	p.Currency = r.Snapshot.Currency
+	return p.update(db, actorID, revision)
+}
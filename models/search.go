@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultSearchLanguage is the text search configuration used when a search
+// doesn't name one. It should match the default of products.search_language.
+var DefaultSearchLanguage = "english"
+
+// searchLanguages are the Postgres text search configurations a search may
+// ask for.
+var searchLanguages = map[string]bool{
+	"simple": true, "danish": true, "dutch": true, "english": true,
+	"finnish": true, "french": true, "german": true, "hungarian": true,
+	"italian": true, "norwegian": true, "portuguese": true, "romanian": true,
+	"russian": true, "spanish": true, "swedish": true, "turkish": true,
+}
+
+var (
+	ErrInvalidSearchLanguage = errors.New("invalid search language")
+	ErrEmptySearchQuery      = errors.New("empty search query")
+)
+
+// searchTerm matches the words of a prefix query; everything else, including
+// tsquery operators, is dropped.
+var searchTerm = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// highlightOptions wraps matches in <mark> tags for ts_headline.
+const highlightOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MinWords=5, MaxWords=20"
+
+// SearchOptions describes a full-text product search.
+type SearchOptions struct {
+	Query string
+	// Language is the text search configuration of the query. Only products
+	// whose SearchLanguage is the same are searched, as stems of other
+	// languages wouldn't match.
+	Language string
+	// Prefix makes every term match words starting with it, e.g. for
+	// search-as-you-type. Otherwise Query uses web search syntax: quoted
+	// phrases, "or" and "-" for exclusion.
+	Prefix bool
+	Filter ProductFilter
+	Limit  int
+	Offset int
+	// Facets asks for facet counts over every match, not just this page.
+	Facets *FacetOptions
+}
+
+// SearchPage is a page of search results. Facets is only set when it was
+// asked for.
+type SearchPage struct {
+	Results []SearchResult `json:"results"`
+	Facets  *Facets        `json:"facets,omitempty"`
+}
+
+// SearchResult is a product matching a search, with its relevance and the
+// matching parts of its name and description highlighted.
+type SearchResult struct {
+	Product
+	Rank       float64          `json:"rank"`
+	Highlights SearchHighlights `json:"highlights"`
+}
+
+type SearchHighlights struct {
+	ProductName        string `json:"product_name"`
+	ProductDescription string `json:"product_description"`
+}
+
+// prefixQuery turns free text into a tsquery matching all of its words as
+// prefixes, e.g. "red sho" becomes "red:* & sho:*".
+func prefixQuery(text string) string {
+	terms := searchTerm.FindAllString(text, -1)
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchProducts returns the products matching a full-text search over
+// their name and description, most relevant first.
+func SearchProducts(db *sql.DB, opts SearchOptions) (*SearchPage, error) {
+	if opts.Language == "" {
+		opts.Language = DefaultSearchLanguage
+	}
+	if !searchLanguages[opts.Language] {
+		return nil, ErrInvalidSearchLanguage
+	}
+
+	b := &QueryBuilder{}
+	language := b.Arg(opts.Language) + "::regconfig"
+
+	var tsquery string
+	if opts.Prefix {
+		terms := prefixQuery(opts.Query)
+		if terms == "" {
+			return nil, ErrEmptySearchQuery
+		}
+		tsquery = fmt.Sprintf("to_tsquery(%s, %s)", language, b.Arg(terms))
+	} else {
+		if strings.TrimSpace(opts.Query) == "" {
+			return nil, ErrEmptySearchQuery
+		}
+		tsquery = fmt.Sprintf("websearch_to_tsquery(%s, %s)", language, b.Arg(opts.Query))
+	}
+
+	b.Where("search_language = " + language)
+	b.Where("search_vector @@ query")
+	b.Where("deleted_at IS NULL")
+	opts.Filter.Apply(b)
+
+	with := fmt.Sprintf("q AS (SELECT %s AS query), ", tsquery)
+	page := &SearchPage{Results: []SearchResult{}}
+	if opts.Facets != nil {
+		err := opts.Facets.Validate()
+		if err != nil {
+			return nil, err
+		}
+		page.Facets, err = queryFacets(db, with, productsWithPromotion+", q", b, *opts.Facets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := PageOptions{Limit: opts.Limit}.limit()
+	query := fmt.Sprintf(`WITH q AS (SELECT %s AS query)
+			  SELECT id, user_id, product_name, product_description, product_images, product_price, currency, compressed_product_images, created_at,
+			  status, publish_at, version, search_language, %s, COALESCE(promo.promotion_id, 0),
+			  ts_rank_cd(search_vector, query) AS rank,
+			  ts_headline(%s, product_name, query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+			  ts_headline(%s, COALESCE(product_description, ''), query, '%s')
+			  FROM %s, q`, tsquery, effectivePrice, language, language, highlightOptions, productsWithPromotion) +
+		b.WhereClause() + fmt.Sprintf(" ORDER BY rank DESC, id LIMIT %d OFFSET %d", limit, opts.Offset)
+
+	rows, err := db.Query(query, b.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("could not search products: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r SearchResult
+		var price decimal.Decimal
+		err := rows.Scan(&r.ID, &r.UserID, &r.ProductName, &r.ProductDescription, pq.Array(&r.ProductImages), &r.ProductPrice, &r.Currency, pq.Array(&r.CompressedProductImages), &r.CreatedAt,
+			&r.Status, &r.PublishAt, &r.Version, &r.SearchLanguage, &price, &r.PromotionID, &r.Rank, &r.Highlights.ProductName, &r.Highlights.ProductDescription)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan search result: %v", err)
+		}
+		r.EffectivePrice = &price
+		page.Results = append(page.Results, r)
+	}
+
+	return page, nil
+}
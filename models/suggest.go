@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DefaultSuggestLimit is the number of suggestions returned by default.
+	DefaultSuggestLimit = 10
+	// MaxSuggestLimit caps the number of suggestions a request may ask for.
+	MaxSuggestLimit = 25
+)
+
+// likeEscaper escapes the LIKE wildcards in user input.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SuggestProductNames returns up to limit distinct names of the user's
+// products, or only of their published products if publishedOnly is set,
+// containing prefix. Names starting with prefix come first, then
+// names ordered by trigram similarity to it. The pattern match is served by
+// the pg_trgm index on product_name.
+func SuggestProductNames(db *sql.DB, userID int, prefix string, limit int, publishedOnly bool) ([]string, error) {
+	if limit <= 0 {
+		limit = DefaultSuggestLimit
+	}
+	if limit > MaxSuggestLimit {
+		limit = MaxSuggestLimit
+	}
+
+	escaped := likeEscaper.Replace(prefix)
+
+	b := &QueryBuilder{}
+	startsWith := b.Arg(escaped + "%")
+	similarTo := b.Arg(prefix)
+	b.Where("user_id = ?", userID)
+	b.Where("deleted_at IS NULL")
+	b.Where("product_name ILIKE ?", "%"+escaped+"%")
+	if publishedOnly {
+		b.Where("status = ?", ProductPublished)
+	}
+
+	query := fmt.Sprintf(`SELECT product_name FROM (
+			  SELECT DISTINCT ON (lower(product_name)) product_name,
+			  product_name ILIKE %s AS starts_with, similarity(product_name, %s) AS score
+			  FROM products`, startsWith, similarTo) + b.WhereClause() + `
+			  ORDER BY lower(product_name), score DESC
+			  ) names ORDER BY starts_with DESC, score DESC, product_name` + fmt.Sprintf(" LIMIT %d", limit)
+
+	rows, err := db.Query(query, b.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("could not suggest product names: %v", err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan product name: %v", err)
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
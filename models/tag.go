@@ -0,0 +1,126 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// MaxTagLength is the longest tag accepted, in bytes.
+const MaxTagLength = 64
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+// tagPattern matches normalized tags such as "clearance" or "new-arrival".
+var tagPattern = regexp.MustCompile(`^[\p{Ll}\p{N}][\p{Ll}\p{N}_-]*$`)
+
+// TagCount is the number of a user's products carrying a tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag trims and lower-cases a tag, and fails with ErrInvalidTag
+// unless the result is made of letters, digits, "-" and "_".
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if len(tag) > MaxTagLength || !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag and drops duplicates.
+func NormalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// GetProductTags returns the tags of a product in alphabetical order, or
+// none if it is in the trash.
+func GetProductTags(db *sql.DB, productID int) ([]string, error) {
+	query := `SELECT product_tags.tag FROM product_tags JOIN products ON products.id = product_tags.product_id
+			  WHERE product_tags.product_id = $1 AND products.deleted_at IS NULL ORDER BY product_tags.tag`
+	rows, err := db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get product tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		err := rows.Scan(&tag)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan tag: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
+}
+
+// AddProductTags tags a product. Tags must already be normalized; tags the
+// product already has are ignored.
+func AddProductTags(db *sql.DB, productID int, tags []string) error {
+	query := `INSERT INTO product_tags (product_id, tag)
+			  SELECT $1, tag FROM unnest($2::text[]) AS tag
+			  ON CONFLICT DO NOTHING`
+	_, err := db.Exec(query, productID, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("could not add product tags: %v", err)
+	}
+	return nil
+}
+
+// RemoveProductTag untags a product. Removing a tag the product doesn't have
+// is not an error.
+func RemoveProductTag(db *sql.DB, productID int, tag string) error {
+	_, err := db.Exec("DELETE FROM product_tags WHERE product_id = $1 AND tag = $2", productID, tag)
+	if err != nil {
+		return fmt.Errorf("could not remove product tag: %v", err)
+	}
+	return nil
+}
+
+// GetUserTags returns every tag used on a user's products with the number of
+// products carrying it, most used first. Products in the trash are left out,
+// and so are those not in status unless it is empty.
+func GetUserTags(db *sql.DB, userID int, status string) ([]TagCount, error) {
+	query := `SELECT product_tags.tag, COUNT(*)
+			  FROM product_tags JOIN products ON products.id = product_tags.product_id
+			  WHERE products.user_id = $1 AND products.deleted_at IS NULL AND ($2 = '' OR products.status = $2)
+			  GROUP BY product_tags.tag ORDER BY COUNT(*) DESC, product_tags.tag`
+	rows, err := db.Query(query, userID, status)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var t TagCount
+		err := rows.Scan(&t.Tag, &t.Count)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan tag: %v", err)
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTrashRetention is how long deleted products stay in the trash
+// before they are purged.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+var ErrNotProductOwner = errors.New("product belongs to another user")
+
+// GetDeletedProducts returns up to limit products of a user that are in the
+// trash, most recently deleted first.
+func GetDeletedProducts(db *sql.DB, userID, limit int) ([]Product, error) {
+	limit = PageOptions{Limit: limit}.limit()
+	query := `SELECT id, user_id, product_name, product_description, product_images, product_price, currency, compressed_product_images, created_at, status, publish_at, version, search_language, deleted_at
+			  FROM products WHERE user_id = $1 AND deleted_at IS NOT NULL
+			  ORDER BY deleted_at DESC, id DESC LIMIT $2`
+	rows, err := db.Query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get deleted products: %v", err)
+	}
+	defer rows.Close()
+
+	products := []Product{}
+	for rows.Next() {
+		var p Product
+		err := rows.Scan(&p.ID, &p.UserID, &p.ProductName, &p.ProductDescription, pq.Array(&p.ProductImages), &p.ProductPrice, &p.Currency, pq.Array(&p.CompressedProductImages), &p.CreatedAt,
+			&p.Status, &p.PublishAt, &p.Version, &p.SearchLanguage, &p.DeletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan product: %v", err)
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// Restore takes the product out of the trash on behalf of its owner,
+// userID. It fails with ErrProductNotFound unless the product is in the
+// trash, and with ErrNotProductOwner if it belongs to someone else. p's
+// UserID and Version are set.
+func (p *Product) Restore(db *sql.DB, userID int) error {
+	query := `UPDATE products SET deleted_at = NULL, version = version + 1
+			  WHERE id = $1 AND deleted_at IS NOT NULL AND user_id = $2 RETURNING user_id, version`
+	err := db.QueryRow(query, p.ID, userID).Scan(&p.UserID, &p.Version)
+	if err == sql.ErrNoRows {
+		var inTrash bool
+		err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NOT NULL)", p.ID).Scan(&inTrash)
+		if err != nil {
+			return fmt.Errorf("could not restore product: %v", err)
+		}
+		if !inTrash {
+			return ErrProductNotFound
+		}
+		return ErrNotProductOwner
+	} else if err != nil {
+		return fmt.Errorf("could not restore product: %v", err)
+	}
+	return nil
+}
+
+// PurgeDeletedProducts permanently deletes up to limit products that were
+// moved to the trash before the given time, each in its own transaction.
+// removeImages is called with the compressed images of each product that no
+// other product uses, before its deletion is committed. If it fails the
+// product stays in the trash and the others are still purged; the first
+// such error is returned along with the purged products, which only have
+// their ID and UserID set.
+func PurgeDeletedProducts(db *sql.DB, before time.Time, limit int, removeImages func([]string) error) ([]Product, error) {
+	purged := []Product{}
+	failed := []int{}
+	var firstErr error
+	for len(purged)+len(failed) < limit {
+		product, err := purgeNextDeletedProduct(db, before, failed, removeImages)
+		if err != nil && product == nil {
+			return purged, err
+		}
+		if product == nil {
+			break
+		}
+		if err != nil {
+			failed = append(failed, product.ID)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		purged = append(purged, *product)
+	}
+	return purged, firstErr
+}
+
+// purgeNextDeletedProduct purges the product that has been in the trash the
+// longest, skipping those in skip. It returns the product along with the
+// error if removeImages failed.
+func purgeNextDeletedProduct(db *sql.DB, before time.Time, skip []int, removeImages func([]string) error) (*Product, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("could not purge product: %v", err)
+	}
+	defer tx.Rollback()
+
+	var p Product
+	query := `SELECT id, user_id FROM products
+			  WHERE deleted_at < $1 AND NOT id = ANY($2)
+			  ORDER BY deleted_at, id LIMIT 1 FOR UPDATE SKIP LOCKED`
+	err = tx.QueryRow(query, before, pq.Array(skip)).Scan(&p.ID, &p.UserID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not purge product: %v", err)
+	}
+
+	// Compressed images are shared by products with the same original image
+	var images []string
+	query = `DELETE FROM products WHERE id = $1
+			  RETURNING ARRAY(
+			  SELECT DISTINCT image FROM unnest(compressed_product_images) AS image
+			  WHERE NOT EXISTS (SELECT 1 FROM products other WHERE other.id <> $1 AND image = ANY(other.compressed_product_images))
+			  )`
+	err = tx.QueryRow(query, p.ID).Scan(pq.Array(&images))
+	if err != nil {
+		return nil, fmt.Errorf("could not purge product: %v", err)
+	}
+
+	if len(images) > 0 {
+		err = removeImages(images)
+		if err != nil {
+			return &p, fmt.Errorf("could not remove images of product %d: %v", p.ID, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("could not purge product: %v", err)
+	}
+	return &p, nil
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+// MaxSKULength is the longest SKU accepted.
+const MaxSKULength = 64
+
+// Unique constraints of product_variants, see database/schema.sql.
+const (
+	variantSKUConstraint     = "product_variants_sku_key"
+	variantOptionsConstraint = "product_variants_product_id_options_key"
+)
+
+var (
+	ErrVariantNotFound        = errors.New("variant not found")
+	ErrInvalidSKU             = errors.New("invalid SKU")
+	ErrInvalidVariantPrice    = errors.New("invalid variant price")
+	ErrInvalidVariantImage    = errors.New("variant image is not an image of the product")
+	ErrDuplicateSKU           = errors.New("SKU already exists")
+	ErrDuplicateVariantOption = errors.New("variant with these options already exists")
+)
+
+// Variant is a purchasable version of a product, e.g. a size and color. It
+// is priced at PriceOverride if set and at the product's price otherwise, in
+// the product's currency; Price is the resulting price. Images are a subset of the product's images.
+type Variant struct {
+	ID            int               `json:"id"`
+	ProductID     int               `json:"product_id"`
+	SKU           string            `json:"sku"`
+	Options       map[string]string `json:"options"`
+	PriceOverride *decimal.Decimal  `json:"price_override"`
+	Price         decimal.Decimal   `json:"price"`
+	Images        []string          `json:"images"`
+	// ConvertedPrice is only set when a client asks for another currency
+	ConvertedPrice *ConvertedPrice `json:"converted_price,omitempty"`
+	// EffectivePrice is Price after the product's promotion, when the
+	// product was read with it
+	EffectivePrice *decimal.Decimal `json:"effective_price,omitempty"`
+	PromotionID    int              `json:"promotion_id,omitempty"`
+}
+
+// variantColumns are selected by every variant query, in scan order.
+// They refer to the variant as v and its product as p.
+const variantColumns = `v.id, v.product_id, v.sku, v.options, v.price_override, COALESCE(v.price_override, p.product_price, 0), v.images`
+
+// Validate checks the variant against the product it belongs to.
+func (v *Variant) Validate(product Product) error {
+	if v.SKU == "" || len(v.SKU) > MaxSKULength {
+		return ErrInvalidSKU
+	}
+	if v.PriceOverride != nil {
+		err := ValidatePrice(*v.PriceOverride, product.Currency)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidVariantPrice, err)
+		}
+	}
+
+	images := make(map[string]bool, len(product.ProductImages))
+	for _, image := range product.ProductImages {
+		images[image] = true
+	}
+	for _, image := range v.Images {
+		if !images[image] {
+			return fmt.Errorf("%w: %s", ErrInvalidVariantImage, image)
+		}
+	}
+	return nil
+}
+
+func (v *Variant) Create(db *sql.DB) error {
+	options, err := v.optionsJSON()
+	if err != nil {
+		return err
+	}
+
+	query := `WITH v AS (
+			  INSERT INTO product_variants (product_id, sku, options, price_override, images)
+			  VALUES ($1, $2, $3, $4, $5) RETURNING *
+			  ) SELECT ` + variantColumns + ` FROM v JOIN products p ON p.id = v.product_id`
+	row := db.QueryRow(query, v.ProductID, v.SKU, options, v.PriceOverride, pq.Array(v.Images))
+	err = v.scan(row)
+	if err != nil {
+		return variantError("could not create variant", err)
+	}
+	return nil
+}
+
+func (v *Variant) GetByID(db *sql.DB, productID, id int) error {
+	query := `SELECT ` + variantColumns + ` FROM product_variants v JOIN products p ON p.id = v.product_id
+			  WHERE v.product_id = $1 AND v.id = $2`
+	err := v.scan(db.QueryRow(query, productID, id))
+	if err == sql.ErrNoRows {
+		return ErrVariantNotFound
+	} else if err != nil {
+		return fmt.Errorf("could not get variant by id: %v", err)
+	}
+	return nil
+}
+
+func (v *Variant) Update(db *sql.DB) error {
+	options, err := v.optionsJSON()
+	if err != nil {
+		return err
+	}
+
+	query := `WITH v AS (
+			  UPDATE product_variants SET sku = $1, options = $2, price_override = $3, images = $4
+			  WHERE product_id = $5 AND id = $6 RETURNING *
+			  ) SELECT ` + variantColumns + ` FROM v JOIN products p ON p.id = v.product_id`
+	row := db.QueryRow(query, v.SKU, options, v.PriceOverride, pq.Array(v.Images), v.ProductID, v.ID)
+	err = v.scan(row)
+	if err == sql.ErrNoRows {
+		return ErrVariantNotFound
+	} else if err != nil {
+		return variantError("could not update variant", err)
+	}
+	return nil
+}
+
+func (v *Variant) Delete(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM product_variants WHERE product_id = $1 AND id = $2", v.ProductID, v.ID)
+	if err != nil {
+		return fmt.Errorf("could not delete variant: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not delete variant: %v", err)
+	}
+	if n == 0 {
+		return ErrVariantNotFound
+	}
+	return nil
+}
+
+// GetProductVariants returns the variants of a product in creation order, or
+// none if it is in the trash.
+func GetProductVariants(db *sql.DB, productID int) ([]Variant, error) {
+	query := `SELECT ` + variantColumns + ` FROM product_variants v JOIN products p ON p.id = v.product_id
+			  WHERE v.product_id = $1 AND p.deleted_at IS NULL ORDER BY v.id`
+	rows, err := db.Query(query, productID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get variants: %v", err)
+	}
+	defer rows.Close()
+
+	variants := []Variant{}
+	for rows.Next() {
+		var v Variant
+		err := v.scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan variant: %v", err)
+		}
+		variants = append(variants, v)
+	}
+
+	return variants, nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (v *Variant) scan(row scanner) error {
+	var options []byte
+	var priceOverride decimal.NullDecimal
+	err := row.Scan(&v.ID, &v.ProductID, &v.SKU, &options, &priceOverride, &v.Price, pq.Array(&v.Images))
+	if err != nil {
+		return err
+	}
+
+	v.PriceOverride = nil
+	if priceOverride.Valid {
+		v.PriceOverride = &priceOverride.Decimal
+	}
+	if v.Images == nil {
+		v.Images = []string{}
+	}
+	return json.Unmarshal(options, &v.Options)
+}
+
+func (v *Variant) optionsJSON() (string, error) {
+	options := v.Options
+	if options == nil {
+		options = map[string]string{}
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return "", fmt.Errorf("could not encode variant options: %v", err)
+	}
+	return string(data), nil
+}
+
+// variantError maps unique violations to the variant errors and wraps
+// everything else.
+func variantError(msg string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation {
+		switch pqErr.Constraint {
+		case variantSKUConstraint:
+			return ErrDuplicateSKU
+		case variantOptionsConstraint:
+			return ErrDuplicateVariantOption
+		}
+	}
+	return fmt.Errorf("%s: %v", msg, err)
+}
@@ -7,10 +7,32 @@ import (
 	"github.com/streadway/amqp"
 )
 
+const (
+	// InteractiveQueueName receives images for products created through the API.
+	InteractiveQueueName = "image_queue"
+	// BulkQueueName receives images enqueued by backfills and other batch jobs.
+	BulkQueueName = "image_queue_bulk"
+)
+
+// Priority selects the lane an image processing job is published to.
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota
+	PriorityBulk
+)
+
+// QueueName returns the name of the queue backing the priority lane.
+func (p Priority) QueueName() string {
+	if p == PriorityBulk {
+		return BulkQueueName
+	}
+	return InteractiveQueueName
+}
+
 type Queue struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
-	queue   amqp.Queue
 }
 
 func NewQueue() (*Queue, error) {
@@ -24,14 +46,7 @@ func NewQueue() (*Queue, error) {
 		return nil, err
 	}
 
-	queue, err := channel.QueueDeclare(
-		"image_queue",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	err = Declare(channel)
 	if err != nil {
 		return nil, err
 	}
@@ -39,14 +54,36 @@ func NewQueue() (*Queue, error) {
 	return &Queue{
 		conn:    conn,
 		channel: channel,
-		queue:   queue,
 	}, nil
 }
 
+// Declare makes sure both priority lanes exist on the given channel. It is
+// shared by publishers and the image processing consumers.
+func Declare(channel *amqp.Channel) error {
+	for _, name := range []string{InteractiveQueueName, BulkQueueName} {
+		_, err := channel.QueueDeclare(
+			name,
+			true,
+			false,
+			false,
+			false,
+			nil,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (q *Queue) AddToQueue(imageURL string) error {
+	return q.AddToQueueWithPriority(imageURL, PriorityInteractive)
+}
+
+func (q *Queue) AddToQueueWithPriority(imageURL string, priority Priority) error {
 	err := q.channel.Publish(
 		"",
-		q.queue.Name,
+		priority.QueueName(),
 		false,
 		false,
 		amqp.Publishing{
@@ -57,10 +94,34 @@ func (q *Queue) AddToQueue(imageURL string) error {
 	if err != nil {
 		return err
 	}
-	log.Printf("Added image URL to queue: %s", imageURL)
+	log.Printf("Added image URL to queue %s: %s", priority.QueueName(), imageURL)
 	return nil
 }
 
+// AddProductImages enqueues the images of a freshly created or edited product
+// on the interactive lane.
+func (q *Queue) AddProductImages(imageURLs []string) error {
+	return q.AddProductImagesWithPriority(imageURLs, PriorityInteractive)
+}
+
+// AddProductImagesWithPriority enqueues images on the given lane. Backfills
+// should use PriorityBulk so they don't delay interactive traffic.
+func (q *Queue) AddProductImagesWithPriority(imageURLs []string, priority Priority) error {
+	for _, imageURL := range imageURLs {
+		err := q.AddToQueueWithPriority(imageURL, priority)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewChannel opens another channel on the queue's connection, so that
+// consumers don't share the publishing channel.
+func (q *Queue) NewChannel() (*amqp.Channel, error) {
+	return q.conn.Channel()
+}
+
 func (q *Queue) Close() {
 	q.channel.Close()
 	q.conn.Close()
@@ -1,50 +1,211 @@
 package services
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
-	"golang.org/x/net/context"
 	"github.com/yourusername/yourproject/models"
+	"golang.org/x/net/context"
 )
 
-var (
-	CacheClient *redis.Client
-	ctx         = context.Background()
+const (
+	// DefaultCacheTTL is how long a product stays fresh after it is read.
+	DefaultCacheTTL = 24 * time.Hour
+	// DefaultStaleTTL is how long an expired product may still be served
+	// while a single request refreshes it in the background.
+	DefaultStaleTTL = 10 * time.Minute
+	// DefaultCacheJitter spreads TTLs by up to ±10% so that products cached
+	// together don't all expire together.
+	DefaultCacheJitter = 0.1
+	// DefaultListCacheTTL bounds how long a listing can be served if an
+	// invalidation is missed.
+	DefaultListCacheTTL = 5 * time.Minute
+	// DefaultNegativeCacheTTL is how long a lookup of a missing product is
+	// remembered. Keep it short: creating a product also clears it, but only
+	// on the instance that created it and those reached by the bus.
+	DefaultNegativeCacheTTL = time.Minute
 )
 
+// Cache write strategies applied by SyncProductCache.
+const (
+	// InvalidateOnWrite evicts an updated product; the next read reloads it.
+	InvalidateOnWrite = "invalidate"
+	// WriteThrough stores the updated product in the cache right away.
+	WriteThrough = "write-through"
+)
+
+// CacheWriteStrategy is either InvalidateOnWrite or WriteThrough.
+var CacheWriteStrategy = InvalidateOnWrite
+
+// ErrCacheMiss is returned by a ProductCache when the product is not cached.
+var ErrCacheMiss = errors.New("product not found in cache")
+
+// CachedProduct is a product cache entry. It is fresh until FreshUntil and
+// stale, but still servable, for the cache's stale TTL after that. NotFound
+// entries record that the product doesn't exist and are never served stale.
+type CachedProduct struct {
+	Product    models.Product `json:"product"`
+	FreshUntil time.Time      `json:"fresh_until"`
+	NotFound   bool           `json:"not_found,omitempty"`
+}
+
+// Fresh reports whether the entry can be served without being refreshed.
+func (c *CachedProduct) Fresh() bool {
+	return time.Now().Before(c.FreshUntil)
+}
+
+// ProductCache caches products served by GET /products/{id} and the
+// filtered listings served by GET /products. Getters return stale products
+// too; use LoadProductByID to get refresh handling.
+//
+// Listings are tagged with the user they belong to so that all of a user's
+// listings can be dropped at once when any of their products changes.
+type ProductCache interface {
+	GetProductByID(id int) (*CachedProduct, error)
+	SetProductByID(id int, product models.Product) error
+	SetProductNotFound(id int) error
+	InvalidateProductCache(id int) error
+
+	GetProductList(userID int, key string) (*models.ProductPage, error)
+	SetProductList(userID int, key string, page *models.ProductPage) error
+	InvalidateUserProductLists(userID int) error
+
+	// EvictLocal drops whatever copies of the invalidated entries this
+	// process holds. It is called for invalidations received from other
+	// instances, which have already cleared any shared storage.
+	EvictLocal(invalidation Invalidation) error
+}
+
+// Cache is the product cache main hands to the controllers and background
+// jobs. It is set by InitCache or InitMemoryCache, and tests may replace it
+// with their own implementation.
+var Cache ProductCache
+
+var ctx = context.Background()
+
+// InitCache backs Cache with Redis.
 func InitCache(redisHost, redisPort string) {
-	CacheClient = redis.NewClient(&redis.Options{
-		Addr: fmt.Sprintf("%s:%s", redisHost, redisPort),
-	})
+	Cache = NewRedisCache(redisHost, redisPort)
+}
+
+// InitMemoryCache backs Cache with an in-process LRU, for running the service
+// without Redis. See InitTieredCache for combining both.
+func InitMemoryCache(capacity int, ttl time.Duration) {
+	Cache = NewLRUCache(capacity, ttl)
+}
+
+// ProductListKey normalizes listing query parameters into a cache key, so
+// that requests differing only in parameter order, blank parameters or
+// surrounding whitespace share an entry.
+func ProductListKey(query url.Values) string {
+	normalized := url.Values{}
+	for name, values := range query {
+		for _, value := range values {
+			value = strings.TrimSpace(value)
+			if value != "" {
+				normalized.Add(name, value)
+			}
+		}
+		sort.Strings(normalized[name])
+	}
+
+	// Encode sorts by parameter name
+	sum := sha1.Sum([]byte(normalized.Encode()))
+	return hex.EncodeToString(sum[:])
+}
+
+func productCacheKey(id int) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+func productListCacheKey(userID int, key string) string {
+	return fmt.Sprintf("products:page:%d:%s", userID, key)
+}
+
+func userProductListsTag(userID int) string {
+	return fmt.Sprintf("products:lists:user:%d", userID)
+}
+
+// jitterTTL randomly lengthens or shortens ttl by up to jitter times ttl.
+func jitterTTL(ttl time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 || ttl <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration((rand.Float64()*2-1)*jitter*float64(ttl))
+}
+
+// newCachedProduct builds an entry with a jittered freshness period.
+func newCachedProduct(product models.Product, ttl time.Duration, jitter float64) CachedProduct {
+	return CachedProduct{
+		Product:    product,
+		FreshUntil: time.Now().Add(jitterTTL(ttl, jitter)),
+	}
+}
+
+// RedisCache stores products in Redis as JSON. Keys live for TTL plus
+// StaleTTL so that stale entries remain available during a refresh, or for
+// NegativeTTL if the product doesn't exist. Listings live for ListTTL and
+// their keys are tracked in a per-user set.
+type RedisCache struct {
+	Client      *redis.Client
+	TTL         time.Duration
+	StaleTTL    time.Duration
+	NegativeTTL time.Duration
+	ListTTL     time.Duration
+	Jitter      float64
+}
+
+func NewRedisCache(redisHost, redisPort string) *RedisCache {
+	return &RedisCache{
+		Client: redis.NewClient(&redis.Options{
+			Addr: fmt.Sprintf("%s:%s", redisHost, redisPort),
+		}),
+		TTL:         DefaultCacheTTL,
+		StaleTTL:    DefaultStaleTTL,
+		NegativeTTL: DefaultNegativeCacheTTL,
+		ListTTL:     DefaultListCacheTTL,
+		Jitter:      DefaultCacheJitter,
+	}
 }
 
-func GetProductByID(id int) (*models.Product, error) {
-	val, err := CacheClient.Get(ctx, fmt.Sprintf("product:%d", id)).Result()
+func (c *RedisCache) GetProductByID(id int) (*CachedProduct, error) {
+	val, err := c.Client.Get(ctx, productCacheKey(id)).Result()
 	if err == redis.Nil {
-		return nil, fmt.Errorf("product not found in cache")
+		return nil, ErrCacheMiss
 	} else if err != nil {
 		return nil, err
 	}
 
-	var product models.Product
-	err = json.Unmarshal([]byte(val), &product)
+	var entry CachedProduct
+	err = json.Unmarshal([]byte(val), &entry)
 	if err != nil {
 		return nil, err
 	}
+	// Entries written before freshness was tracked can't be trusted.
+	if entry.FreshUntil.IsZero() {
+		return nil, ErrCacheMiss
+	}
 
-	return &product, nil
+	return &entry, nil
 }
 
-func SetProductByID(id int, product models.Product) error {
-	data, err := json.Marshal(product)
+func (c *RedisCache) SetProductByID(id int, product models.Product) error {
+	entry := newCachedProduct(product, c.TTL, c.Jitter)
+	data, err := json.Marshal(entry)
 	if err != nil {
 		return err
 	}
 
-	err = CacheClient.Set(ctx, fmt.Sprintf("product:%d", id), data, 24*time.Hour).Err()
+	err = c.Client.Set(ctx, productCacheKey(id), data, time.Until(entry.FreshUntil)+c.StaleTTL).Err()
 	if err != nil {
 		return err
 	}
@@ -52,11 +213,76 @@ func SetProductByID(id int, product models.Product) error {
 	return nil
 }
 
-func InvalidateProductCache(id int) error {
-	err := CacheClient.Del(ctx, fmt.Sprintf("product:%d", id)).Err()
+func (c *RedisCache) SetProductNotFound(id int) error {
+	entry := CachedProduct{FreshUntil: time.Now().Add(c.NegativeTTL), NotFound: true}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return c.Client.Set(ctx, productCacheKey(id), data, c.NegativeTTL).Err()
+}
+
+func (c *RedisCache) InvalidateProductCache(id int) error {
+	err := c.Client.Del(ctx, productCacheKey(id)).Err()
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func (c *RedisCache) GetProductList(userID int, key string) (*models.ProductPage, error) {
+	val, err := c.Client.Get(ctx, productListCacheKey(userID, key)).Result()
+	if err == redis.Nil {
+		return nil, ErrCacheMiss
+	} else if err != nil {
+		return nil, err
+	}
+
+	var page models.ProductPage
+	err = json.Unmarshal([]byte(val), &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+func (c *RedisCache) SetProductList(userID int, key string, page *models.ProductPage) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+
+	listKey := productListCacheKey(userID, key)
+	tag := userProductListsTag(userID)
+	_, err = c.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, listKey, data, c.ListTTL)
+		pipe.SAdd(ctx, tag, listKey)
+		// The tag set only needs to outlive the newest listing it tracks
+		pipe.Expire(ctx, tag, c.ListTTL)
+		return nil
+	})
+	return err
+}
+
+func (c *RedisCache) InvalidateUserProductLists(userID int) error {
+	tag := userProductListsTag(userID)
+	keys, err := c.Client.SMembers(ctx, tag).Result()
+	if err != nil {
+		return err
+	}
+
+	err = c.Client.Del(ctx, append(keys, tag)...).Err()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EvictLocal is a no-op: Redis is shared, so the publisher already cleared it.
+func (c *RedisCache) EvictLocal(invalidation Invalidation) error {
+	return nil
+}
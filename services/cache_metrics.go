@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+// Cache operation results recorded by CacheMetrics.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheOK    = "ok"
+	CacheError = "error"
+)
+
+// CacheMetrics counts product cache operations by result and accumulates
+// their latency.
+type CacheMetrics struct {
+	mu  sync.Mutex
+	ops map[string]*CacheOpStats
+}
+
+// CacheOpStats is a snapshot of the counters for one cache operation.
+type CacheOpStats struct {
+	Results      map[string]uint64
+	Count        uint64
+	TotalLatency time.Duration
+}
+
+// CacheStats collects the metrics of the instrumented Cache and is served on
+// /metrics.
+var CacheStats = NewCacheMetrics()
+
+func NewCacheMetrics() *CacheMetrics {
+	return &CacheMetrics{ops: make(map[string]*CacheOpStats)}
+}
+
+// Observe records one call of op that took latency and ended with result.
+func (m *CacheMetrics) Observe(op, result string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.ops[op]
+	if !ok {
+		stats = &CacheOpStats{Results: make(map[string]uint64)}
+		m.ops[op] = stats
+	}
+	stats.Results[result]++
+	stats.Count++
+	stats.TotalLatency += latency
+}
+
+// Snapshot returns a copy of the counters keyed by operation.
+func (m *CacheMetrics) Snapshot() map[string]CacheOpStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[string]CacheOpStats, len(m.ops))
+	for op, stats := range m.ops {
+		results := make(map[string]uint64, len(stats.Results))
+		for result, n := range stats.Results {
+			results[result] = n
+		}
+		snapshot[op] = CacheOpStats{Results: results, Count: stats.Count, TotalLatency: stats.TotalLatency}
+	}
+	return snapshot
+}
+
+// WritePrometheus writes the counters in the Prometheus text format.
+func (m *CacheMetrics) WritePrometheus(w io.Writer) error {
+	snapshot := m.Snapshot()
+	ops := make([]string, 0, len(snapshot))
+	for op := range snapshot {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	lines := []string{
+		"# HELP product_cache_operations_total Product cache operations by result.",
+		"# TYPE product_cache_operations_total counter",
+	}
+	for _, op := range ops {
+		results := make([]string, 0, len(snapshot[op].Results))
+		for result := range snapshot[op].Results {
+			results = append(results, result)
+		}
+		sort.Strings(results)
+		for _, result := range results {
+			lines = append(lines, fmt.Sprintf("product_cache_operations_total{op=%q,result=%q} %d", op, result, snapshot[op].Results[result]))
+		}
+	}
+
+	lines = append(lines,
+		"# HELP product_cache_operation_duration_seconds Time spent in product cache operations.",
+		"# TYPE product_cache_operation_duration_seconds summary",
+	)
+	for _, op := range ops {
+		lines = append(lines,
+			fmt.Sprintf("product_cache_operation_duration_seconds_sum{op=%q} %g", op, snapshot[op].TotalLatency.Seconds()),
+			fmt.Sprintf("product_cache_operation_duration_seconds_count{op=%q} %d", op, snapshot[op].Count),
+		)
+	}
+
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstrumentedCache records every call to the wrapped ProductCache in Metrics.
+type InstrumentedCache struct {
+	Cache   ProductCache
+	Metrics *CacheMetrics
+}
+
+func NewInstrumentedCache(cache ProductCache, metrics *CacheMetrics) *InstrumentedCache {
+	return &InstrumentedCache{
+		Cache:   cache,
+		Metrics: metrics,
+	}
+}
+
+// InstrumentCache wraps Cache so that its operations are reported in
+// CacheStats. Call it after the cache has been initialized.
+func InstrumentCache() {
+	Cache = NewInstrumentedCache(Cache, CacheStats)
+}
+
+func (c *InstrumentedCache) observeRead(op string, start time.Time, err error) {
+	result := CacheHit
+	if err == ErrCacheMiss {
+		result = CacheMiss
+	} else if err != nil {
+		result = CacheError
+	}
+	c.Metrics.Observe(op, result, time.Since(start))
+}
+
+func (c *InstrumentedCache) observeWrite(op string, start time.Time, err error) {
+	result := CacheOK
+	if err != nil {
+		result = CacheError
+	}
+	c.Metrics.Observe(op, result, time.Since(start))
+}
+
+func (c *InstrumentedCache) GetProductByID(id int) (*CachedProduct, error) {
+	start := time.Now()
+	entry, err := c.Cache.GetProductByID(id)
+	c.observeRead("get_product", start, err)
+	return entry, err
+}
+
+func (c *InstrumentedCache) SetProductByID(id int, product models.Product) error {
+	start := time.Now()
+	err := c.Cache.SetProductByID(id, product)
+	c.observeWrite("set_product", start, err)
+	return err
+}
+
+func (c *InstrumentedCache) SetProductNotFound(id int) error {
+	start := time.Now()
+	err := c.Cache.SetProductNotFound(id)
+	c.observeWrite("set_product_not_found", start, err)
+	return err
+}
+
+func (c *InstrumentedCache) InvalidateProductCache(id int) error {
+	start := time.Now()
+	err := c.Cache.InvalidateProductCache(id)
+	c.observeWrite("invalidate_product", start, err)
+	return err
+}
+
+func (c *InstrumentedCache) GetProductList(userID int, key string) (*models.ProductPage, error) {
+	start := time.Now()
+	page, err := c.Cache.GetProductList(userID, key)
+	c.observeRead("get_list", start, err)
+	return page, err
+}
+
+func (c *InstrumentedCache) SetProductList(userID int, key string, page *models.ProductPage) error {
+	start := time.Now()
+	err := c.Cache.SetProductList(userID, key, page)
+	c.observeWrite("set_list", start, err)
+	return err
+}
+
+func (c *InstrumentedCache) InvalidateUserProductLists(userID int) error {
+	start := time.Now()
+	err := c.Cache.InvalidateUserProductLists(userID)
+	c.observeWrite("invalidate_lists", start, err)
+	return err
+}
+
+func (c *InstrumentedCache) EvictLocal(invalidation Invalidation) error {
+	start := time.Now()
+	err := c.Cache.EvictLocal(invalidation)
+	c.observeWrite("evict_local", start, err)
+	return err
+}
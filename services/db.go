@@ -0,0 +1,44 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+
+	_ "github.com/lib/pq"
+)
+
+// DB is the database handed to the controllers and background jobs. It is
+// set by InitDB.
+var DB *sql.DB
+
+// OpenDB connects to Postgres and checks that the connection works.
+func OpenDB(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %v", err)
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not connect to database: %v", err)
+	}
+	return db, nil
+}
+
+// NewDB connects to the database named by the DB_HOST, DB_PORT, DB_USER,
+// DB_PASSWORD and DB_NAME environment variables.
+func NewDB() (*sql.DB, error) {
+	return OpenDB(fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME")))
+}
+
+// InitDB connects DB, exiting if the database can't be reached.
+func InitDB(dsn string) {
+	db, err := OpenDB(dsn)
+	if err != nil {
+		Logger.Fatalf("Failed to initialize database: %v", err)
+	}
+	DB = db
+}
@@ -8,63 +8,192 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 
-	"github.com/streadway/amqp"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/sirupsen/logrus"
-	"github.com/lib/pq"
+	"github.com/streadway/amqp"
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/queue"
+)
+
+const (
+	// DefaultImageWorkers is the size of the worker pool shared by both lanes.
+	DefaultImageWorkers = 4
+	// DefaultBulkImageWorkers is how many of those workers prefer the bulk lane.
+	DefaultBulkImageWorkers = 1
 )
 
 type ImageProcessor struct {
 	DB     *sql.DB
 	S3     *s3.S3
 	Queue  *amqp.Channel
+	Cache  ProductCache
 	Logger *logrus.Logger
+
+	// Invalidations, if set, is used to tell API instances to evict products
+	// whose compressed images were just written.
+	Invalidations *InvalidationBus
+
+	// Workers is the total number of concurrent image jobs. BulkWorkers of
+	// them pick bulk jobs first; the rest pick interactive jobs first. Either
+	// kind of worker falls back to the other lane when its own is empty, so
+	// idle capacity is never wasted and neither lane can be starved. See
+	// ImageWorkers.
+	Workers     int
+	BulkWorkers int
+}
+
+// ImageWorker is the lane preference of one image worker. A worker that
+// alternates prefers the lane it didn't take its last job from.
+type ImageWorker struct {
+	Primary   queue.Priority
+	Alternate bool
 }
 
-func NewImageProcessor(db *sql.DB, s3 *s3.S3, queue *amqp.Channel, logger *logrus.Logger) *ImageProcessor {
+// ImageWorkers apportions a pool of workers between the lanes. At least one
+// worker prefers the interactive lane, so bulkWorkers is capped at one less
+// than workers; a single worker alternates between the lanes if bulkWorkers
+// is set, so that bulk jobs aren't stuck behind interactive ones.
+func ImageWorkers(workers, bulkWorkers int) []ImageWorker {
+	if workers == 1 && bulkWorkers > 0 {
+		return []ImageWorker{{Primary: queue.PriorityInteractive, Alternate: true}}
+	}
+	if bulkWorkers >= workers {
+		bulkWorkers = workers - 1
+	}
+
+	pool := []ImageWorker{}
+	for i := 0; i < workers; i++ {
+		worker := ImageWorker{Primary: queue.PriorityInteractive}
+		if i < bulkWorkers {
+			worker.Primary = queue.PriorityBulk
+		}
+		pool = append(pool, worker)
+	}
+	return pool
+}
+
+func NewImageProcessor(db *sql.DB, s3 *s3.S3, queue *amqp.Channel, cache ProductCache, logger *logrus.Logger) *ImageProcessor {
 	return &ImageProcessor{
-		DB:     db,
-		S3:     s3,
-		Queue:  queue,
-		Logger: logger,
+		DB:          db,
+		S3:          s3,
+		Queue:       queue,
+		Cache:       cache,
+		Logger:      logger,
+		Workers:     DefaultImageWorkers,
+		BulkWorkers: DefaultBulkImageWorkers,
 	}
 }
 
 func (ip *ImageProcessor) ProcessImages() {
-	msgs, err := ip.Queue.Consume(
-		"image_queue", // queue
-		"",            // consumer
-		true,          // auto-ack
-		false,         // exclusive
-		false,         // no-local
-		false,         // no-wait
-		nil,           // args
-	)
+	err := queue.Declare(ip.Queue)
+	if err != nil {
+		ip.Logger.Fatalf("Failed to declare image queues: %v", err)
+	}
+
+	// Keep undelivered jobs in the broker so that the lane a worker takes
+	// from next is decided at the time it becomes free.
+	err = ip.Queue.Qos(ip.Workers, 0, false)
+	if err != nil {
+		ip.Logger.Fatalf("Failed to set queue prefetch: %v", err)
+	}
+
+	interactive, err := ip.consume(queue.InteractiveQueueName)
+	if err != nil {
+		ip.Logger.Fatalf("Failed to register a consumer: %v", err)
+	}
+	bulk, err := ip.consume(queue.BulkQueueName)
 	if err != nil {
 		ip.Logger.Fatalf("Failed to register a consumer: %v", err)
 	}
 
-	for msg := range msgs {
-		imageURL := string(msg.Body)
-		ip.Logger.Infof("Processing image: %s", imageURL)
+	var wg sync.WaitGroup
+	for _, worker := range ImageWorkers(ip.Workers, ip.BulkWorkers) {
+		primary, secondary := interactive, bulk
+		if worker.Primary == queue.PriorityBulk {
+			primary, secondary = bulk, interactive
+		}
 
-		compressedImageURL, err := ip.downloadAndCompressImage(imageURL)
-		if err != nil {
-			ip.Logger.Errorf("Failed to process image: %v", err)
-			continue
+		wg.Add(1)
+		go func(alternate bool) {
+			defer wg.Done()
+			RunImageWorker(primary, secondary, alternate, ip.processDelivery)
+		}(worker.Alternate)
+	}
+	wg.Wait()
+}
+
+func (ip *ImageProcessor) consume(queueName string) (<-chan amqp.Delivery, error) {
+	return ip.Queue.Consume(
+		queueName, // queue
+		"",        // consumer
+		false,     // auto-ack
+		false,     // exclusive
+		false,     // no-local
+		false,     // no-wait
+		nil,       // args
+	)
+}
+
+// RunImageWorker passes jobs to process, taking them from primary whenever
+// one is waiting and only from secondary otherwise. If alternate is set the
+// lanes swap after each job taken from primary. It returns once a lane is
+// closed.
+func RunImageWorker(primary, secondary <-chan amqp.Delivery, alternate bool, process func(amqp.Delivery)) {
+	for {
+		var msg amqp.Delivery
+		var ok bool
+		fromPrimary := true
+
+		select {
+		case msg, ok = <-primary:
+		default:
+			select {
+			case msg, ok = <-primary:
+			case msg, ok = <-secondary:
+				fromPrimary = false
+			}
+		}
+		if !ok {
+			return
 		}
 
-		err = ip.updateCompressedImageURLInDB(imageURL, compressedImageURL)
-		if err != nil {
-			ip.Logger.Errorf("Failed to update compressed image URL in DB: %v", err)
-			continue
+		process(msg)
+		if alternate && fromPrimary {
+			primary, secondary = secondary, primary
 		}
+	}
+}
+
+func (ip *ImageProcessor) processDelivery(msg amqp.Delivery) {
+	imageURL := string(msg.Body)
+	ip.Logger.Infof("Processing image from %s: %s", msg.RoutingKey, imageURL)
+
+	compressedImageURL, err := ip.downloadAndCompressImage(imageURL)
+	if err != nil {
+		ip.Logger.Errorf("Failed to process image: %v", err)
+		msg.Nack(false, false)
+		return
+	}
 
-		ip.Logger.Infof("Successfully processed image: %s", imageURL)
+	updated, err := ip.updateCompressedImageURLInDB(imageURL, compressedImageURL)
+	if err != nil {
+		ip.Logger.Errorf("Failed to update compressed image URL in DB: %v", err)
+		msg.Nack(false, false)
+		return
+	}
+
+	for _, product := range updated {
+		err = invalidateProduct(ip.Cache, ip.Invalidations, Invalidation{ProductID: product.ID, UserID: product.UserID})
+		if err != nil {
+			ip.Logger.Errorf("Failed to invalidate cached product %d: %v", product.ID, err)
+		}
 	}
+
+	msg.Ack(false)
+	ip.Logger.Infof("Successfully processed image: %s", imageURL)
 }
 
 func (ip *ImageProcessor) downloadAndCompressImage(imageURL string) (string, error) {
@@ -97,12 +226,27 @@ func (ip *ImageProcessor) downloadAndCompressImage(imageURL string) (string, err
 	return compressedImageURL, nil
 }
 
-func (ip *ImageProcessor) updateCompressedImageURLInDB(originalImageURL, compressedImageURL string) error {
-	query := `UPDATE products SET compressed_product_images = array_append(compressed_product_images, $1) 
-			  WHERE $2 = ANY(product_images)`
-	_, err := ip.DB.Exec(query, compressedImageURL, originalImageURL)
+// updateCompressedImageURLInDB records the compressed image on every product
+// using the original image and returns the ID and owner of those products.
+func (ip *ImageProcessor) updateCompressedImageURLInDB(originalImageURL, compressedImageURL string) ([]models.Product, error) {
+	// Backfills may process an image again
+	query := `UPDATE products SET compressed_product_images = array_append(compressed_product_images, $1), version = version + 1 
+			  WHERE $2 = ANY(product_images) AND NOT $1 = ANY(compressed_product_images) RETURNING id, user_id`
+	rows, err := ip.DB.Query(query, compressedImageURL, originalImageURL)
 	if err != nil {
-		return fmt.Errorf("failed to update compressed image URL in DB: %v", err)
+		return nil, fmt.Errorf("failed to update compressed image URL in DB: %v", err)
+	}
+	defer rows.Close()
+
+	var updated []models.Product
+	for rows.Next() {
+		var product models.Product
+		err := rows.Scan(&product.ID, &product.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan updated product: %v", err)
+		}
+		updated = append(updated, product)
 	}
-	return nil
+
+	return updated, rows.Err()
 }
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/yourusername/yourproject/models"
+)
+
+// InvalidationChannel is the Redis pub/sub channel on which product
+// invalidations are broadcast to every API instance.
+const InvalidationChannel = "product_invalidations"
+
+// Invalidation tells cache holders to drop a product and its owner's
+// listings. ProductID is zero when only the listings changed, and UserID is
+// zero when the owner is unknown.
+type Invalidation struct {
+	ProductID int `json:"product_id,omitempty"`
+	UserID    int `json:"user_id,omitempty"`
+}
+
+// InvalidationBus broadcasts invalidations over Redis pub/sub, so that API
+// instances keeping products in process memory evict them when another
+// instance or the image processor changes a product.
+type InvalidationBus struct {
+	Client  *redis.Client
+	Channel string
+}
+
+// Invalidations is the bus main hands to the controllers and background
+// jobs. It is nil unless InitInvalidationBus has been called, in which case
+// invalidations only affect the local cache.
+var Invalidations *InvalidationBus
+
+func NewInvalidationBus(redisHost, redisPort string) *InvalidationBus {
+	return &InvalidationBus{
+		Client: redis.NewClient(&redis.Options{
+			Addr: fmt.Sprintf("%s:%s", redisHost, redisPort),
+		}),
+		Channel: InvalidationChannel,
+	}
+}
+
+func InitInvalidationBus(redisHost, redisPort string) {
+	Invalidations = NewInvalidationBus(redisHost, redisPort)
+}
+
+func (b *InvalidationBus) Publish(invalidation Invalidation) error {
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		return err
+	}
+
+	err = b.Client.Publish(ctx, b.Channel, data).Err()
+	if err != nil {
+		return fmt.Errorf("failed to publish invalidation: %v", err)
+	}
+	return nil
+}
+
+// Listen calls handle for every invalidation published on the bus, including
+// those published by this instance. It blocks until the subscription is
+// closed. Messages published while the connection is down are lost, which is
+// why cache entries keep a TTL.
+func (b *InvalidationBus) Listen(handle func(Invalidation)) {
+	pubsub := b.Client.Subscribe(ctx, b.Channel)
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		var invalidation Invalidation
+		err := json.Unmarshal([]byte(msg.Payload), &invalidation)
+		if err != nil {
+			Logger.Warnf("Ignoring malformed invalidation %q: %v", msg.Payload, err)
+			continue
+		}
+		handle(invalidation)
+	}
+}
+
+// ListenForInvalidations evicts products from cache as invalidations arrive
+// on bus. It blocks, so run it in its own goroutine.
+func ListenForInvalidations(bus *InvalidationBus, cache ProductCache) {
+	bus.Listen(func(invalidation Invalidation) {
+		err := cache.EvictLocal(invalidation)
+		if err != nil {
+			Logger.Warnf("Failed to evict product %d: %v", invalidation.ProductID, err)
+		}
+	})
+}
+
+// InvalidateProduct evicts a product and every cached listing of its owner
+// from cache, and tells the other instances to do the same through bus,
+// which may be nil. Pass a zero userID to only evict the product.
+func InvalidateProduct(cache ProductCache, bus *InvalidationBus, id, userID int) error {
+	return invalidateProduct(cache, bus, Invalidation{ProductID: id, UserID: userID})
+}
+
+// InvalidateUserProductLists evicts every cached listing of a user, and tells
+// the other instances to do the same.
+func InvalidateUserProductLists(cache ProductCache, bus *InvalidationBus, userID int) error {
+	return invalidateProduct(cache, bus, Invalidation{UserID: userID})
+}
+
+// SyncProductCache updates cache after product has been written to the
+// database, according to CacheWriteStrategy. The owner's listings are always
+// evicted, and other instances always drop their local copies.
+func SyncProductCache(cache ProductCache, bus *InvalidationBus, product models.Product) error {
+	if CacheWriteStrategy != WriteThrough {
+		return InvalidateProduct(cache, bus, product.ID, product.UserID)
+	}
+
+	err := cache.SetProductByID(product.ID, product)
+	if err != nil {
+		return err
+	}
+	err = cache.InvalidateUserProductLists(product.UserID)
+	if err != nil {
+		return err
+	}
+
+	if bus != nil {
+		return bus.Publish(Invalidation{ProductID: product.ID, UserID: product.UserID})
+	}
+	return nil
+}
+
+func invalidateProduct(cache ProductCache, bus *InvalidationBus, invalidation Invalidation) error {
+	err := evictProduct(cache, invalidation)
+	if err != nil {
+		return err
+	}
+
+	if bus != nil {
+		return bus.Publish(invalidation)
+	}
+	return nil
+}
+
+// evictProduct drops the product and the listings of its owner, whichever
+// the invalidation names.
+func evictProduct(cache ProductCache, invalidation Invalidation) error {
+	if invalidation.ProductID != 0 {
+		err := cache.InvalidateProductCache(invalidation.ProductID)
+		if err != nil {
+			return err
+		}
+	}
+	if invalidation.UserID != 0 {
+		return cache.InvalidateUserProductLists(invalidation.UserID)
+	}
+	return nil
+}
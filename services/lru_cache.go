@@ -0,0 +1,200 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+// DefaultMemoryCacheSize is the number of entries kept by InitMemoryCache
+// callers that have no better estimate.
+const DefaultMemoryCacheSize = 10000
+
+// LRUCache is an in-process ProductCache that evicts the least recently used
+// entry once it holds capacity entries. Products are fresh for TTL and are
+// dropped once they have been stale for StaleTTL; missing products are
+// remembered for NegativeTTL and listings expire after ListTTL.
+type LRUCache struct {
+	TTL         time.Duration
+	StaleTTL    time.Duration
+	NegativeTTL time.Duration
+	ListTTL     time.Duration
+	Jitter      float64
+
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+	tags     map[string]map[*list.Element]struct{}
+}
+
+type lruEntry struct {
+	key       string
+	tag       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+func NewLRUCache(capacity int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		TTL:         ttl,
+		StaleTTL:    DefaultStaleTTL,
+		NegativeTTL: DefaultNegativeCacheTTL,
+		ListTTL:     DefaultListCacheTTL,
+		Jitter:      DefaultCacheJitter,
+		capacity:    capacity,
+		order:       list.New(),
+		items:       make(map[string]*list.Element),
+		tags:        make(map[string]map[*list.Element]struct{}),
+	}
+}
+
+func (c *LRUCache) GetProductByID(id int) (*CachedProduct, error) {
+	value, ok := c.get(productCacheKey(id))
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	entry := value.(CachedProduct)
+	return &entry, nil
+}
+
+func (c *LRUCache) SetProductByID(id int, product models.Product) error {
+	entry := newCachedProduct(product, c.TTL, c.Jitter)
+	c.set(productCacheKey(id), entry, time.Until(entry.FreshUntil)+c.StaleTTL)
+	return nil
+}
+
+func (c *LRUCache) SetProductNotFound(id int) error {
+	entry := CachedProduct{FreshUntil: time.Now().Add(c.NegativeTTL), NotFound: true}
+	c.set(productCacheKey(id), entry, c.NegativeTTL)
+	return nil
+}
+
+// setProductEntry stores an entry read from another cache for TTL, keeping
+// its original freshness.
+func (c *LRUCache) setProductEntry(id int, entry CachedProduct) {
+	c.set(productCacheKey(id), entry, c.TTL)
+}
+
+func (c *LRUCache) InvalidateProductCache(id int) error {
+	c.delete(productCacheKey(id))
+	return nil
+}
+
+func (c *LRUCache) GetProductList(userID int, key string) (*models.ProductPage, error) {
+	value, ok := c.get(productListCacheKey(userID, key))
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	return value.(*models.ProductPage), nil
+}
+
+func (c *LRUCache) SetProductList(userID int, key string, page *models.ProductPage) error {
+	c.setTagged(productListCacheKey(userID, key), userProductListsTag(userID), page, c.ListTTL)
+	return nil
+}
+
+func (c *LRUCache) InvalidateUserProductLists(userID int) error {
+	c.deleteTag(userProductListsTag(userID))
+	return nil
+}
+
+// EvictLocal drops the product and listings; everything an LRUCache holds is
+// local to this process.
+func (c *LRUCache) EvictLocal(invalidation Invalidation) error {
+	return evictProduct(c, invalidation)
+}
+
+// Len returns the number of entries currently held, including expired entries
+// that have not been looked up since they expired.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRUCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeElement(elem)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *LRUCache) set(key string, value interface{}, ttl time.Duration) {
+	c.setTagged(key, "", value, ttl)
+}
+
+// setTagged stores value under key and, if tag is not empty, records it
+// under tag so that deleteTag removes it.
+func (c *LRUCache) setTagged(key, tag string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+
+	elem := c.order.PushFront(&lruEntry{key: key, tag: tag, value: value, expiresAt: expiresAt})
+	c.items[key] = elem
+	if tag != "" {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[*list.Element]struct{})
+		}
+		c.tags[tag][elem] = struct{}{}
+	}
+
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *LRUCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+func (c *LRUCache) deleteTag(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := range c.tags[tag] {
+		c.removeElement(elem)
+	}
+}
+
+func (c *LRUCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*lruEntry)
+	c.order.Remove(elem)
+	delete(c.items, entry.key)
+	if entry.tag != "" {
+		delete(c.tags[entry.tag], elem)
+		if len(c.tags[entry.tag]) == 0 {
+			delete(c.tags, entry.tag)
+		}
+	}
+}
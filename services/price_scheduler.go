@@ -0,0 +1,80 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/yourproject/models"
+)
+
+const (
+	// DefaultPriceSchedulerInterval is how often due price changes are
+	// looked for, and so how late they may be applied.
+	DefaultPriceSchedulerInterval = time.Minute
+	// DefaultPriceSchedulerBatch is how many price changes are applied
+	// before checking for more.
+	DefaultPriceSchedulerBatch = 100
+)
+
+// PriceScheduler applies scheduled price changes once they are due. Several
+// instances may run at once; each change is applied by only one of them.
+type PriceScheduler struct {
+	DB     *sql.DB
+	Cache  ProductCache
+	Logger *logrus.Logger
+
+	// Invalidations, if set, is used to tell API instances to evict products
+	// whose price changed.
+	Invalidations *InvalidationBus
+
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewPriceScheduler(db *sql.DB, cache ProductCache, logger *logrus.Logger) *PriceScheduler {
+	return &PriceScheduler{
+		DB:        db,
+		Cache:     cache,
+		Logger:    logger,
+		Interval:  DefaultPriceSchedulerInterval,
+		BatchSize: DefaultPriceSchedulerBatch,
+	}
+}
+
+// Run applies due price changes every Interval. It blocks, so run it in its
+// own goroutine.
+func (ps *PriceScheduler) Run() {
+	ticker := time.NewTicker(ps.Interval)
+	defer ticker.Stop()
+
+	for {
+		ps.ApplyDue()
+		<-ticker.C
+	}
+}
+
+// ApplyDue applies every price change that is due and returns how many it
+// applied.
+func (ps *PriceScheduler) ApplyDue() int {
+	total := 0
+	for {
+		applied, err := models.ApplyDuePrices(ps.DB, time.Now(), ps.BatchSize)
+		for _, change := range applied {
+			ps.Logger.Infof("Applied scheduled price %d: product %d now costs %s %s", change.ID, change.ProductID, change.Price.Amount, change.Price.Currency)
+			err := invalidateProduct(ps.Cache, ps.Invalidations, Invalidation{ProductID: change.ProductID, UserID: change.UserID})
+			if err != nil {
+				ps.Logger.Warnf("Failed to invalidate product %d: %v", change.ProductID, err)
+			}
+		}
+		total += len(applied)
+
+		if err != nil {
+			ps.Logger.Errorf("Failed to apply scheduled prices: %v", err)
+			return total
+		}
+		if len(applied) < ps.BatchSize {
+			return total
+		}
+	}
+}
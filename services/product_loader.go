@@ -0,0 +1,78 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/yourusername/yourproject/models"
+	"golang.org/x/sync/singleflight"
+)
+
+// productLoads coalesces concurrent database loads of the same product so
+// that a popular product expiring from the cache costs a single query.
+var productLoads singleflight.Group
+
+// LoadProductByID returns the product from cache and calls load to fetch it
+// on a miss. Concurrent misses for the same ID share one call to load. A stale
+// entry is returned immediately while one background load refreshes it.
+//
+// If load fails with sql.ErrNoRows the miss is cached too, and lookups of
+// the same ID fail with an error wrapping sql.ErrNoRows until it expires.
+func LoadProductByID(cache ProductCache, id int, load func() (models.Product, error)) (*models.Product, error) {
+	entry, err := cache.GetProductByID(id)
+	if err == nil && entry.NotFound {
+		if entry.Fresh() {
+			return nil, fmt.Errorf("product %d: %w", id, sql.ErrNoRows)
+		}
+		err = ErrCacheMiss
+	}
+	if err == nil {
+		if !entry.Fresh() {
+			// DoChan joins an in-flight refresh instead of starting another
+			// one; the buffered result channel is simply never read.
+			refresh := loadAndCacheProduct(cache, id, load)
+			productLoads.DoChan(strconv.Itoa(id), func() (interface{}, error) {
+				value, err := refresh()
+				if err != nil {
+					Logger.Warnf("Failed to refresh product %d: %v", id, err)
+				}
+				return value, err
+			})
+		}
+		return &entry.Product, nil
+	}
+	if err != ErrCacheMiss {
+		Logger.Warnf("Failed to read product %d from cache: %v", id, err)
+	}
+
+	value, err, _ := productLoads.Do(strconv.Itoa(id), loadAndCacheProduct(cache, id, load))
+	if err != nil {
+		return nil, err
+	}
+
+	product := value.(models.Product)
+	return &product, nil
+}
+
+func loadAndCacheProduct(cache ProductCache, id int, load func() (models.Product, error)) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		product, err := load()
+		if errors.Is(err, sql.ErrNoRows) {
+			cacheErr := cache.SetProductNotFound(id)
+			if cacheErr != nil {
+				Logger.Warnf("Failed to cache missing product %d: %v", id, cacheErr)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = cache.SetProductByID(id, product)
+		if err != nil {
+			Logger.Warnf("Failed to cache product %d: %v", id, err)
+		}
+		return product, nil
+	}
+}
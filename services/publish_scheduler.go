@@ -0,0 +1,81 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/yourproject/models"
+)
+
+const (
+	// DefaultPublishSchedulerInterval is how often drafts due to be
+	// published are looked for, and so how late they may be published.
+	DefaultPublishSchedulerInterval = time.Minute
+	// DefaultPublishSchedulerBatch is how many products are published before
+	// checking for more.
+	DefaultPublishSchedulerBatch = 100
+)
+
+// PublishScheduler publishes drafts once their scheduled publish time has
+// passed. Several instances may run at once.
+type PublishScheduler struct {
+	DB     *sql.DB
+	Cache  ProductCache
+	Logger *logrus.Logger
+
+	// Invalidations, if set, is used to tell API instances to evict the
+	// published products.
+	Invalidations *InvalidationBus
+
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewPublishScheduler(db *sql.DB, cache ProductCache, logger *logrus.Logger) *PublishScheduler {
+	return &PublishScheduler{
+		DB:        db,
+		Cache:     cache,
+		Logger:    logger,
+		Interval:  DefaultPublishSchedulerInterval,
+		BatchSize: DefaultPublishSchedulerBatch,
+	}
+}
+
+// Run publishes due drafts every Interval. It blocks, so run it in its own
+// goroutine.
+func (ps *PublishScheduler) Run() {
+	ticker := time.NewTicker(ps.Interval)
+	defer ticker.Stop()
+
+	for {
+		ps.PublishDue()
+		<-ticker.C
+	}
+}
+
+// PublishDue publishes every draft that is due and returns how many it
+// published.
+func (ps *PublishScheduler) PublishDue() int {
+	total := 0
+	for {
+		published, err := models.PublishDueProducts(ps.DB, time.Now(), ps.BatchSize)
+		if err != nil {
+			ps.Logger.Errorf("Failed to publish scheduled products: %v", err)
+			return total
+		}
+
+		for _, product := range published {
+			ps.Logger.Infof("Published scheduled product %d", product.ID)
+			err := invalidateProduct(ps.Cache, ps.Invalidations, Invalidation{ProductID: product.ID, UserID: product.UserID})
+			if err != nil {
+				ps.Logger.Warnf("Failed to invalidate product %d: %v", product.ID, err)
+			}
+		}
+		total += len(published)
+
+		if len(published) < ps.BatchSize {
+			return total
+		}
+	}
+}
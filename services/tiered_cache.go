@@ -0,0 +1,113 @@
+package services
+
+import (
+	"time"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+const (
+	// DefaultL1CacheSize is the number of entries each instance keeps in
+	// process when running a TieredCache.
+	DefaultL1CacheSize = 1000
+	// DefaultL1CacheTTL bounds how long an instance can serve a local copy
+	// if it misses an invalidation.
+	DefaultL1CacheTTL = 30 * time.Second
+)
+
+// TieredCache keeps a small in-process L1 in front of a shared L2, normally
+// Redis. Writes go to L2 and drop the local copy; other instances drop
+// theirs when the invalidation reaches them through the InvalidationBus.
+type TieredCache struct {
+	L1 *LRUCache
+	L2 ProductCache
+}
+
+func NewTieredCache(l1 *LRUCache, l2 ProductCache) *TieredCache {
+	return &TieredCache{
+		L1: l1,
+		L2: l2,
+	}
+}
+
+// InitTieredCache backs Cache with a per-instance LRU in front of Redis.
+func InitTieredCache(redisHost, redisPort string) {
+	l1 := NewLRUCache(DefaultL1CacheSize, DefaultL1CacheTTL)
+	l1.ListTTL = DefaultL1CacheTTL
+	Cache = NewTieredCache(l1, NewRedisCache(redisHost, redisPort))
+}
+
+func (c *TieredCache) GetProductByID(id int) (*CachedProduct, error) {
+	entry, err := c.L1.GetProductByID(id)
+	if err == nil {
+		return entry, nil
+	}
+
+	entry, err = c.L2.GetProductByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep L2's freshness so that stale entries are refreshed on time
+	c.L1.setProductEntry(id, *entry)
+	return entry, nil
+}
+
+func (c *TieredCache) SetProductByID(id int, product models.Product) error {
+	err := c.L2.SetProductByID(id, product)
+	if err != nil {
+		return err
+	}
+
+	// The next read copies the entry back from L2 with L2's freshness
+	return c.L1.InvalidateProductCache(id)
+}
+
+func (c *TieredCache) SetProductNotFound(id int) error {
+	err := c.L2.SetProductNotFound(id)
+	if err != nil {
+		return err
+	}
+
+	return c.L1.InvalidateProductCache(id)
+}
+
+func (c *TieredCache) InvalidateProductCache(id int) error {
+	c.L1.InvalidateProductCache(id)
+	return c.L2.InvalidateProductCache(id)
+}
+
+func (c *TieredCache) GetProductList(userID int, key string) (*models.ProductPage, error) {
+	page, err := c.L1.GetProductList(userID, key)
+	if err == nil {
+		return page, nil
+	}
+
+	page, err = c.L2.GetProductList(userID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.L1.SetProductList(userID, key, page)
+	return page, nil
+}
+
+func (c *TieredCache) SetProductList(userID int, key string, page *models.ProductPage) error {
+	err := c.L2.SetProductList(userID, key, page)
+	if err != nil {
+		return err
+	}
+
+	return c.L1.SetProductList(userID, key, page)
+}
+
+func (c *TieredCache) InvalidateUserProductLists(userID int) error {
+	c.L1.InvalidateUserProductLists(userID)
+	return c.L2.InvalidateUserProductLists(userID)
+}
+
+// EvictLocal only drops the L1 copies; the instance that published the
+// invalidation has already cleared L2.
+func (c *TieredCache) EvictLocal(invalidation Invalidation) error {
+	return c.L1.EvictLocal(invalidation)
+}
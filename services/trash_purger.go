@@ -0,0 +1,107 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/yourproject/models"
+)
+
+const (
+	// DefaultTrashPurgerInterval is how often the trash is checked for
+	// products past their retention.
+	DefaultTrashPurgerInterval = time.Hour
+	// DefaultTrashPurgerBatch is how many products are purged before
+	// checking for more.
+	DefaultTrashPurgerBatch = 100
+)
+
+// TrashPurger permanently deletes products that have been in the trash for
+// longer than Retention, along with the compressed images the image
+// processor stored for them in S3.
+type TrashPurger struct {
+	DB *sql.DB
+	// S3 and Bucket locate the compressed images. Without them, products
+	// with compressed images are left in the trash.
+	S3     *s3.S3
+	Bucket string
+	Logger *logrus.Logger
+
+	Retention time.Duration
+	Interval  time.Duration
+	BatchSize int
+}
+
+func NewTrashPurger(db *sql.DB, s3 *s3.S3, bucket string, logger *logrus.Logger) *TrashPurger {
+	return &TrashPurger{
+		DB:        db,
+		S3:        s3,
+		Bucket:    bucket,
+		Logger:    logger,
+		Retention: models.DefaultTrashRetention,
+		Interval:  DefaultTrashPurgerInterval,
+		BatchSize: DefaultTrashPurgerBatch,
+	}
+}
+
+// Run purges expired products every Interval. It blocks, so run it in its
+// own goroutine.
+func (tp *TrashPurger) Run() {
+	ticker := time.NewTicker(tp.Interval)
+	defer ticker.Stop()
+
+	for {
+		tp.Purge()
+		<-ticker.C
+	}
+}
+
+// Purge deletes every product past its retention and returns how many it
+// deleted.
+func (tp *TrashPurger) Purge() int {
+	total := 0
+	for {
+		purged, err := models.PurgeDeletedProducts(tp.DB, time.Now().Add(-tp.Retention), tp.BatchSize, tp.removeImages)
+		for _, product := range purged {
+			tp.Logger.Infof("Purged product %d from the trash", product.ID)
+		}
+		total += len(purged)
+
+		if err != nil {
+			tp.Logger.Errorf("Failed to purge deleted products: %v", err)
+			return total
+		}
+		if len(purged) < tp.BatchSize {
+			return total
+		}
+	}
+}
+
+// removeImages deletes compressed images from S3. Images stored elsewhere
+// are left alone.
+func (tp *TrashPurger) removeImages(images []string) error {
+	if tp.S3 == nil || tp.Bucket == "" {
+		return fmt.Errorf("no S3 bucket to remove %d images from", len(images))
+	}
+
+	prefix := fmt.Sprintf("https://%s.s3.amazonaws.com/", tp.Bucket)
+	for _, image := range images {
+		if !strings.HasPrefix(image, prefix) {
+			continue
+		}
+
+		_, err := tp.S3.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(tp.Bucket),
+			Key:    aws.String(strings.TrimPrefix(image, prefix)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete %s from S3: %v", image, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,322 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yourusername/yourproject/models"
+	"github.com/yourusername/yourproject/services"
+)
+
+func TestLRUCacheGetSet(t *testing.T) {
+	cache := services.NewLRUCache(10, time.Minute)
+
+	// A product that was never stored is a miss
+	if _, err := cache.GetProductByID(1); err != services.ErrCacheMiss {
+		t.Fatalf("Expected cache miss, got %v", err)
+	}
+
+	product := models.Product{ID: 1, UserID: 1, ProductName: "Test Product"}
+	if err := cache.SetProductByID(product.ID, product); err != nil {
+		t.Fatalf("Failed to cache product: %v", err)
+	}
+
+	cached, err := cache.GetProductByID(product.ID)
+	if err != nil {
+		t.Fatalf("Failed to get cached product: %v", err)
+	}
+	if cached.Product.ProductName != product.ProductName {
+		t.Errorf("Cache returned unexpected product name: got %v want %v", cached.Product.ProductName, product.ProductName)
+	}
+	if !cached.Fresh() {
+		t.Errorf("Expected newly cached product to be fresh")
+	}
+
+	// Invalidation removes the entry
+	if err := cache.InvalidateProductCache(product.ID); err != nil {
+		t.Fatalf("Failed to invalidate product: %v", err)
+	}
+	if _, err := cache.GetProductByID(product.ID); err != services.ErrCacheMiss {
+		t.Errorf("Expected cache miss after invalidation, got %v", err)
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := services.NewLRUCache(2, time.Minute)
+
+	cache.SetProductByID(1, models.Product{ID: 1})
+	cache.SetProductByID(2, models.Product{ID: 2})
+
+	// Touch product 1 so that product 2 becomes the least recently used
+	cache.GetProductByID(1)
+	cache.SetProductByID(3, models.Product{ID: 3})
+
+	if _, err := cache.GetProductByID(2); err != services.ErrCacheMiss {
+		t.Errorf("Expected product 2 to be evicted, got %v", err)
+	}
+	if _, err := cache.GetProductByID(1); err != nil {
+		t.Errorf("Expected product 1 to be cached, got %v", err)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Cache holds unexpected number of entries: got %v want %v", cache.Len(), 2)
+	}
+}
+
+func TestLRUCacheExpiresEntries(t *testing.T) {
+	cache := services.NewLRUCache(10, 10*time.Millisecond)
+	cache.StaleTTL = 0
+	cache.Jitter = 0
+
+	cache.SetProductByID(1, models.Product{ID: 1})
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := cache.GetProductByID(1); err != services.ErrCacheMiss {
+		t.Errorf("Expected expired product to be a miss, got %v", err)
+	}
+}
+
+func TestLoadProductByIDCoalescesMisses(t *testing.T) {
+	services.InitLogger()
+	services.InitMemoryCache(10, time.Minute)
+
+	var loads int32
+	load := func() (models.Product, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(20 * time.Millisecond)
+		return models.Product{ID: 1, ProductName: "Test Product"}, nil
+	}
+
+	// Concurrent misses for the same product should share one load
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			product, err := services.LoadProductByID(services.Cache, 1, load)
+			if err != nil {
+				t.Errorf("Failed to load product: %v", err)
+				return
+			}
+			if product.ProductName != "Test Product" {
+				t.Errorf("Loader returned unexpected product name: got %v want %v", product.ProductName, "Test Product")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Loader hit the database unexpected number of times: got %v want %v", n, 1)
+	}
+}
+
+func TestLoadProductByIDServesStale(t *testing.T) {
+	services.InitLogger()
+	cache := services.NewLRUCache(10, 10*time.Millisecond)
+	cache.StaleTTL = time.Minute
+	cache.Jitter = 0
+	services.Cache = cache
+
+	cache.SetProductByID(1, models.Product{ID: 1, ProductName: "Old Name"})
+	time.Sleep(20 * time.Millisecond)
+
+	refreshed := make(chan struct{})
+	load := func() (models.Product, error) {
+		defer close(refreshed)
+		return models.Product{ID: 1, ProductName: "New Name"}, nil
+	}
+
+	// The stale entry is served while the refresh happens in the background
+	product, err := services.LoadProductByID(services.Cache, 1, load)
+	if err != nil {
+		t.Fatalf("Failed to load product: %v", err)
+	}
+	if product.ProductName != "Old Name" {
+		t.Errorf("Loader returned unexpected product name: got %v want %v", product.ProductName, "Old Name")
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatalf("Stale product was not refreshed")
+	}
+
+	// Wait for the refreshed product to be written back
+	deadline := time.Now().Add(time.Second)
+	for {
+		entry, err := cache.GetProductByID(1)
+		if err == nil && entry.Product.ProductName == "New Name" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Refreshed product was not cached")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestProductListKeyNormalizesQuery(t *testing.T) {
+	a, _ := url.ParseQuery("user_id=1&min_price=10&product_name=%20shoe%20&max_price=")
+	b, _ := url.ParseQuery("product_name=shoe&min_price=10&user_id=1")
+	c, _ := url.ParseQuery("user_id=1&min_price=20&product_name=shoe")
+
+	if services.ProductListKey(a) != services.ProductListKey(b) {
+		t.Errorf("Equivalent queries produced different cache keys")
+	}
+	if services.ProductListKey(a) == services.ProductListKey(c) {
+		t.Errorf("Different queries produced the same cache key")
+	}
+}
+
+func TestLRUCacheInvalidatesUserLists(t *testing.T) {
+	cache := services.NewLRUCache(10, time.Minute)
+	products := []models.Product{{ID: 1, UserID: 1}, {ID: 2, UserID: 1}}
+
+	cache.SetProductList(1, "a", &models.ProductPage{Products: products})
+	cache.SetProductList(1, "b", &models.ProductPage{Products: products[:1]})
+	cache.SetProductList(2, "a", &models.ProductPage{})
+
+	cached, err := cache.GetProductList(1, "a")
+	if err != nil {
+		t.Fatalf("Failed to get cached listing: %v", err)
+	}
+	if len(cached.Products) != 2 {
+		t.Errorf("Cache returned unexpected number of products: got %v want %v", len(cached.Products), 2)
+	}
+
+	// Dropping user 1's listings leaves other users untouched
+	cache.InvalidateUserProductLists(1)
+	for _, key := range []string{"a", "b"} {
+		if _, err := cache.GetProductList(1, key); err != services.ErrCacheMiss {
+			t.Errorf("Expected listing %s to be invalidated, got %v", key, err)
+		}
+	}
+	if _, err := cache.GetProductList(2, "a"); err != nil {
+		t.Errorf("Expected listing of user 2 to be cached, got %v", err)
+	}
+}
+
+func TestInvalidateProductEvictsProductAndLists(t *testing.T) {
+	services.InitLogger()
+	services.InitMemoryCache(10, time.Minute)
+
+	services.Cache.SetProductByID(1, models.Product{ID: 1, UserID: 1})
+	services.Cache.SetProductList(1, "a", &models.ProductPage{Products: []models.Product{{ID: 1, UserID: 1}}})
+
+	if err := services.InvalidateProduct(services.Cache, nil, 1, 1); err != nil {
+		t.Fatalf("Failed to invalidate product: %v", err)
+	}
+	if _, err := services.Cache.GetProductByID(1); err != services.ErrCacheMiss {
+		t.Errorf("Expected product to be evicted, got %v", err)
+	}
+	if _, err := services.Cache.GetProductList(1, "a"); err != services.ErrCacheMiss {
+		t.Errorf("Expected listing to be evicted, got %v", err)
+	}
+}
+
+func TestTieredCacheEvictsReplicaCopies(t *testing.T) {
+	// Two replicas with their own L1 sharing one L2
+	l2 := services.NewLRUCache(10, time.Minute)
+	replicaA := services.NewTieredCache(services.NewLRUCache(10, time.Minute), l2)
+	replicaB := services.NewTieredCache(services.NewLRUCache(10, time.Minute), l2)
+
+	replicaA.SetProductByID(1, models.Product{ID: 1, UserID: 1, ProductName: "Old Name"})
+	if _, err := replicaB.GetProductByID(1); err != nil {
+		t.Fatalf("Expected replica B to read the product from L2, got %v", err)
+	}
+
+	// Replica A writes; replica B still has its L1 copy until it is told
+	invalidation := services.Invalidation{ProductID: 1, UserID: 1}
+	replicaA.InvalidateProductCache(1)
+	if _, err := replicaB.GetProductByID(1); err != nil {
+		t.Fatalf("Expected replica B to serve its L1 copy, got %v", err)
+	}
+
+	if err := replicaB.EvictLocal(invalidation); err != nil {
+		t.Fatalf("Failed to evict local copy: %v", err)
+	}
+	if _, err := replicaB.GetProductByID(1); err != services.ErrCacheMiss {
+		t.Errorf("Expected cache miss after local eviction, got %v", err)
+	}
+}
+
+func TestInstrumentedCacheCountsHitsAndMisses(t *testing.T) {
+	metrics := services.NewCacheMetrics()
+	cache := services.NewInstrumentedCache(services.NewLRUCache(10, time.Minute), metrics)
+
+	cache.GetProductByID(1)
+	cache.SetProductByID(1, models.Product{ID: 1})
+	cache.GetProductByID(1)
+	cache.GetProductByID(1)
+
+	stats := metrics.Snapshot()["get_product"]
+	if stats.Results[services.CacheHit] != 2 || stats.Results[services.CacheMiss] != 1 {
+		t.Errorf("Unexpected get_product counters: got %v", stats.Results)
+	}
+	if stats.Count != 3 {
+		t.Errorf("Unexpected get_product count: got %v want %v", stats.Count, 3)
+	}
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	want := `product_cache_operations_total{op="get_product",result="hit"} 2`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("Metrics output is missing %q:\n%s", want, buf.String())
+	}
+}
+
+func TestLoadProductByIDCachesNotFound(t *testing.T) {
+	services.InitLogger()
+	services.InitMemoryCache(10, time.Minute)
+
+	var loads int32
+	load := func() (models.Product, error) {
+		atomic.AddInt32(&loads, 1)
+		return models.Product{}, fmt.Errorf("could not get product by id: %w", sql.ErrNoRows)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := services.LoadProductByID(services.Cache, 1, load)
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Fatalf("Expected not found error, got %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("Loader hit the database unexpected number of times: got %v want %v", n, 1)
+	}
+}
+
+func TestSyncProductCacheWriteThrough(t *testing.T) {
+	services.InitLogger()
+	services.InitMemoryCache(10, time.Minute)
+	services.CacheWriteStrategy = services.WriteThrough
+	defer func() { services.CacheWriteStrategy = services.InvalidateOnWrite }()
+
+	services.Cache.SetProductByID(1, models.Product{ID: 1, UserID: 1, ProductName: "Old Name"})
+	services.Cache.SetProductList(1, "a", &models.ProductPage{Products: []models.Product{{ID: 1, UserID: 1, ProductName: "Old Name"}}})
+
+	if err := services.SyncProductCache(services.Cache, nil, models.Product{ID: 1, UserID: 1, ProductName: "New Name"}); err != nil {
+		t.Fatalf("Failed to sync product cache: %v", err)
+	}
+
+	entry, err := services.Cache.GetProductByID(1)
+	if err != nil {
+		t.Fatalf("Expected updated product to be cached, got %v", err)
+	}
+	if entry.Product.ProductName != "New Name" {
+		t.Errorf("Cache returned unexpected product name: got %v want %v", entry.Product.ProductName, "New Name")
+	}
+	if _, err := services.Cache.GetProductList(1, "a"); err != services.ErrCacheMiss {
+		t.Errorf("Expected listing to be evicted, got %v", err)
+	}
+}
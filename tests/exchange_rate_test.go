@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestRoundingModes(t *testing.T) {
+	tests := []struct {
+		rounding models.Rounding
+		amount   string
+		want     string
+	}{
+		{models.RoundHalfEven, "1.125", "1.12"},
+		{models.RoundHalfEven, "1.135", "1.14"},
+		{models.RoundHalfEven, "1.1251", "1.13"},
+		{models.RoundHalfUp, "1.125", "1.13"},
+		{models.RoundDown, "1.129", "1.12"},
+		{models.RoundUp, "1.121", "1.13"},
+	}
+
+	for _, tt := range tests {
+		got := tt.rounding.Round(decimal.RequireFromString(tt.amount), 2)
+		if !got.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("%s.Round(%s) = %s, want %s", tt.rounding, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestParseRounding(t *testing.T) {
+	rounding, err := models.ParseRounding("")
+	if err != nil || rounding != models.RoundHalfEven {
+		t.Errorf("Expected half_even by default, got %q, %v", rounding, err)
+	}
+
+	_, err = models.ParseRounding("half_down")
+	if !errors.Is(err, models.ErrInvalidRounding) {
+		t.Errorf("Expected ErrInvalidRounding, got %v", err)
+	}
+}
+
+func TestParseExchangeRatesCSV(t *testing.T) {
+	rates, err := models.ParseExchangeRatesCSV(strings.NewReader("base,quote,rate\nEUR,USD,1.0842\nusd, jpy, 151.37\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("Unexpected number of rates: got %v want %v", len(rates), 2)
+	}
+	if !rates[0].Rate.Equal(decimal.RequireFromString("1.0842")) {
+		t.Errorf("Unexpected rate: %v", rates[0].Rate)
+	}
+
+	err = rates[1].Validate()
+	if err != nil || rates[1].Base != "USD" || rates[1].Quote != "JPY" {
+		t.Errorf("Expected USD/JPY, got %s/%s, %v", rates[1].Base, rates[1].Quote, err)
+	}
+
+	_, err = models.ParseExchangeRatesCSV(strings.NewReader("EUR,USD,abc\n"))
+	if !errors.Is(err, models.ErrInvalidExchangeRate) {
+		t.Errorf("Expected ErrInvalidExchangeRate, got %v", err)
+	}
+}
+
+func TestExchangeRateValidate(t *testing.T) {
+	invalid := []models.ExchangeRate{
+		{Base: "EUR", Quote: "EUR", Rate: decimal.NewFromInt(1)},
+		{Base: "EUR", Quote: "USD", Rate: decimal.Zero},
+		{Base: "EUR", Quote: "XXX", Rate: decimal.NewFromInt(1)},
+	}
+	for _, rate := range invalid {
+		if err := rate.Validate(); err == nil {
+			t.Errorf("Expected %s/%s %s to be invalid", rate.Base, rate.Quote, rate.Rate)
+		}
+	}
+}
+
+func TestConvertProductPricesPrefersListPrice(t *testing.T) {
+	override := decimal.RequireFromString("24.99")
+	product := &models.Product{
+		ID:           1,
+		ProductPrice: decimal.RequireFromString("19.99"),
+		Currency:     "USD",
+		Variants:     []models.Variant{{SKU: "SAMPLE-M"}},
+	}
+	listPrices := map[int]decimal.Decimal{1: decimal.RequireFromString("18.50")}
+
+	// No USD to EUR rate is stored, but the product has its own EUR price
+	err := models.ConvertProductPrices([]*models.Product{product}, "EUR", nil, listPrices, models.RoundHalfEven)
+	if err != nil {
+		t.Fatalf("Failed to convert product: %v", err)
+	}
+	if !product.ConvertedPrice.Amount.Equal(listPrices[1]) || product.ConvertedPrice.Rate != nil {
+		t.Errorf("Expected the list price without a rate, got %+v", product.ConvertedPrice)
+	}
+	if product.Variants[0].ConvertedPrice != product.ConvertedPrice {
+		t.Errorf("Expected the variant to share the list price, got %+v", product.Variants[0].ConvertedPrice)
+	}
+
+	// A price override is in USD, so it still needs a rate
+	product.Variants = []models.Variant{{SKU: "SAMPLE-L", PriceOverride: &override}}
+	err = models.ConvertProductPrices([]*models.Product{product}, "EUR", nil, listPrices, models.RoundHalfEven)
+	if !errors.Is(err, models.ErrNoExchangeRate) {
+		t.Errorf("Expected ErrNoExchangeRate, got %v", err)
+	}
+}
+
+func TestConvertProductPricesConvertsEffectivePrice(t *testing.T) {
+	effective := decimal.RequireFromString("16.00")
+	override := decimal.RequireFromString("25.00")
+	overrideEffective := decimal.RequireFromString("20.00")
+	product := &models.Product{
+		ID:             1,
+		ProductPrice:   decimal.RequireFromString("20.00"),
+		Currency:       "USD",
+		EffectivePrice: &effective,
+		Variants:       []models.Variant{{SKU: "SAMPLE-L", PriceOverride: &override, EffectivePrice: &overrideEffective}},
+	}
+	rates := map[string]models.ExchangeRate{"USD": {Base: "USD", Quote: "EUR", Rate: decimal.RequireFromString("0.9")}}
+
+	err := models.ConvertProductPrices([]*models.Product{product}, "EUR", rates, nil, models.RoundHalfEven)
+	if err != nil {
+		t.Fatalf("Failed to convert product: %v", err)
+	}
+	if got := product.ConvertedPrice.EffectivePrice; got == nil || !got.Equal(decimal.RequireFromString("14.40")) {
+		t.Errorf("Expected an effective price of 14.40 EUR, got %v", got)
+	}
+	if got := product.Variants[0].ConvertedPrice.EffectivePrice; got == nil || !got.Equal(decimal.RequireFromString("18.00")) {
+		t.Errorf("Expected a variant effective price of 18.00 EUR, got %v", got)
+	}
+
+	// The promotion takes 20% off, so it takes 20% off the product's own
+	// EUR price as well
+	product.Variants = nil
+	listPrices := map[int]decimal.Decimal{1: decimal.RequireFromString("18.50")}
+	err = models.ConvertProductPrices([]*models.Product{product}, "EUR", nil, listPrices, models.RoundHalfEven)
+	if err != nil {
+		t.Fatalf("Failed to convert product: %v", err)
+	}
+	if got := product.ConvertedPrice.EffectivePrice; got == nil || !got.Equal(decimal.RequireFromString("14.80")) {
+		t.Errorf("Expected an effective price of 14.80 EUR, got %v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func decimals(amounts ...string) []decimal.Decimal {
+	result := make([]decimal.Decimal, len(amounts))
+	for i, amount := range amounts {
+		result[i] = decimal.RequireFromString(amount)
+	}
+	return result
+}
+
+func TestFacetOptionsValidate(t *testing.T) {
+	tests := []struct {
+		buckets []decimal.Decimal
+		valid   bool
+	}{
+		{nil, true},
+		{decimals("10"), true},
+		{decimals("10", "50", "100"), true},
+		{decimals("10", "10.00"), false},
+		{decimals("50", "10"), false},
+	}
+
+	for _, tt := range tests {
+		err := models.FacetOptions{PriceBuckets: tt.buckets}.Validate()
+		if (err == nil) != tt.valid {
+			t.Errorf("Validate(%v) = %v, want valid %v", tt.buckets, err, tt.valid)
+		}
+	}
+}
+
+func TestGetAllProductsRejectsInvalidPriceBuckets(t *testing.T) {
+	// Validation happens before the database is used
+	page := models.PageOptions{Facets: &models.FacetOptions{PriceBuckets: decimals("50", "10")}}
+	_, err := models.GetAllProducts(nil, models.ProductFilter{UserID: 1}, page)
+	if !errors.Is(err, models.ErrInvalidPriceBuckets) {
+		t.Errorf("Expected ErrInvalidPriceBuckets, got %v", err)
+	}
+}
+
+func TestQueryBuilderClone(t *testing.T) {
+	b := &models.QueryBuilder{}
+	b.Where("user_id = ?", 1)
+
+	clone := b.Clone()
+	if got := clone.Arg("extra"); got != "$2" {
+		t.Errorf("Unexpected placeholder: got %v want %v", got, "$2")
+	}
+	clone.Where("product_price > ?", 10)
+
+	if got := b.WhereClause(); got != " WHERE user_id = $1" {
+		t.Errorf("Clone changed the original WHERE clause: %q", got)
+	}
+	if len(b.Args()) != 1 {
+		t.Errorf("Clone changed the original args: %v", b.Args())
+	}
+}
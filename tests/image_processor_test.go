@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/yourusername/yourproject/queue"
+	"github.com/yourusername/yourproject/services"
+)
+
+func TestImageWorkers(t *testing.T) {
+	interactive := services.ImageWorker{Primary: queue.PriorityInteractive}
+	bulk := services.ImageWorker{Primary: queue.PriorityBulk}
+	cases := []struct {
+		workers, bulkWorkers int
+		want                 []services.ImageWorker
+	}{
+		{4, 1, []services.ImageWorker{bulk, interactive, interactive, interactive}},
+		{4, 0, []services.ImageWorker{interactive, interactive, interactive, interactive}},
+		// At least one worker prefers the interactive lane
+		{2, 5, []services.ImageWorker{bulk, interactive}},
+		// A single worker takes turns
+		{1, 1, []services.ImageWorker{{Primary: queue.PriorityInteractive, Alternate: true}}},
+		{1, 0, []services.ImageWorker{interactive}},
+	}
+	for _, c := range cases {
+		got := services.ImageWorkers(c.workers, c.bulkWorkers)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ImageWorkers(%d, %d) = %+v, want %+v", c.workers, c.bulkWorkers, got, c.want)
+		}
+	}
+}
+
+func TestRunImageWorkerPrefersLane(t *testing.T) {
+	cases := []struct {
+		name      string
+		bulkFirst bool
+		alternate bool
+		want      string
+	}{
+		{"interactive first", false, false, "i1 i2 i3 b1 b2"},
+		{"bulk first", true, false, "b1 b2 i1 i2 i3"},
+		{"alternating", false, true, "i1 b1 i2 b2 i3"},
+	}
+	for _, c := range cases {
+		// Both lanes have jobs waiting before the worker starts
+		interactive := make(chan amqp.Delivery, 3)
+		bulk := make(chan amqp.Delivery, 2)
+		for _, body := range []string{"i1", "i2", "i3"} {
+			interactive <- amqp.Delivery{Body: []byte(body)}
+		}
+		for _, body := range []string{"b1", "b2"} {
+			bulk <- amqp.Delivery{Body: []byte(body)}
+		}
+
+		primary, secondary := interactive, bulk
+		if c.bulkFirst {
+			primary, secondary = bulk, interactive
+		}
+
+		// The worker returns once the lanes are closed after the last job
+		var processed []string
+		services.RunImageWorker(primary, secondary, c.alternate, func(msg amqp.Delivery) {
+			processed = append(processed, string(msg.Body))
+			if len(processed) == 5 {
+				close(interactive)
+				close(bulk)
+			}
+		})
+
+		if got := strings.Join(processed, " "); got != c.want {
+			t.Errorf("%s: processed %q, want %q", c.name, got, c.want)
+		}
+	}
+}
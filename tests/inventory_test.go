@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestInventoryValidatesInput(t *testing.T) {
+	// Validation happens before the database is used
+	_, err := models.AdjustStock(nil, 1, 0, 5, "sold-on-the-side", "")
+	if !errors.Is(err, models.ErrInvalidReason) {
+		t.Errorf("Expected ErrInvalidReason, got %v", err)
+	}
+
+	// Sales go through reservations, not adjustments
+	_, err = models.AdjustStock(nil, 1, 0, -1, models.ReasonSale, "")
+	if !errors.Is(err, models.ErrInvalidReason) {
+		t.Errorf("Expected ErrInvalidReason, got %v", err)
+	}
+
+	_, err = models.AdjustStock(nil, 1, 0, 0, models.ReasonRestock, "")
+	if !errors.Is(err, models.ErrInvalidQuantity) {
+		t.Errorf("Expected ErrInvalidQuantity, got %v", err)
+	}
+
+	_, err = models.ReserveStock(nil, 1, 0, 0)
+	if !errors.Is(err, models.ErrInvalidQuantity) {
+		t.Errorf("Expected ErrInvalidQuantity, got %v", err)
+	}
+
+	_, err = models.SetLowStockThreshold(nil, 1, 0, -1)
+	if !errors.Is(err, models.ErrInvalidQuantity) {
+		t.Errorf("Expected ErrInvalidQuantity, got %v", err)
+	}
+}
+
+func TestProductFilterLowStock(t *testing.T) {
+	b := &models.QueryBuilder{}
+	models.ProductFilter{UserID: 1, LowStock: true}.Apply(b)
+
+	want := " WHERE user_id = $1 AND id IN (SELECT product_id FROM inventory WHERE on_hand - reserved <= low_stock_threshold)"
+	if got := b.WhereClause(); got != want {
+		t.Errorf("Unexpected WHERE clause:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestValidatePrice(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		err      error
+	}{
+		{"19.99", "USD", nil},
+		{"19.90", "USD", nil},
+		{"0", "EUR", nil},
+		{"1500", "JPY", nil},
+		{"1.234", "KWD", nil},
+		{"19.999", "USD", models.ErrInvalidPrice},
+		{"1.5", "JPY", models.ErrInvalidPrice},
+		{"-1", "USD", models.ErrInvalidPrice},
+		{"1000000000000", "USD", models.ErrInvalidPrice},
+		{"1", "XXX", models.ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		err := models.ValidatePrice(decimal.RequireFromString(tt.amount), tt.currency)
+		if tt.err == nil && err != nil {
+			t.Errorf("ValidatePrice(%s %s): unexpected error %v", tt.amount, tt.currency, err)
+		} else if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("ValidatePrice(%s %s): expected %v, got %v", tt.amount, tt.currency, tt.err, err)
+		}
+	}
+}
+
+func TestProductValidateDefaultsCurrency(t *testing.T) {
+	product := models.Product{ProductPrice: decimal.RequireFromString("19.99")}
+	if err := product.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if product.Currency != models.DefaultCurrency {
+		t.Errorf("Unexpected currency: got %v want %v", product.Currency, models.DefaultCurrency)
+	}
+
+	product = models.Product{ProductPrice: decimal.RequireFromString("19.99"), Currency: "eur"}
+	if err := product.Validate(); err != nil || product.Currency != "EUR" {
+		t.Errorf("Expected EUR, got %v, %v", product.Currency, err)
+	}
+}
+
+func TestProductPriceIsExact(t *testing.T) {
+	// 0.1 + 0.2 is not 0.3 in floating point
+	var product models.Product
+	err := json.Unmarshal([]byte(`{"product_price": 0.1}`), &product)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal product: %v", err)
+	}
+	sum := product.ProductPrice.Add(decimal.RequireFromString("0.2"))
+	if !sum.Equal(decimal.RequireFromString("0.3")) {
+		t.Errorf("Unexpected sum: got %v want 0.3", sum)
+	}
+}
@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestParseProductSort(t *testing.T) {
+	cases := []struct {
+		input string
+		want  models.ProductSort
+	}{
+		{"", models.DefaultProductSort},
+		{"price", models.ProductSort{Field: "price"}},
+		{"price:desc", models.ProductSort{Field: "price", Desc: true}},
+		{"name:asc", models.ProductSort{Field: "name"}},
+		{"created_at:desc", models.ProductSort{Field: "created_at", Desc: true}},
+	}
+	for _, c := range cases {
+		got, err := models.ParseProductSort(c.input)
+		if err != nil {
+			t.Errorf("ParseProductSort(%q) returned error: %v", c.input, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseProductSort(%q) = %v, want %v", c.input, got, c.want)
+		}
+	}
+
+	for _, input := range []string{"id", "price:up", "product_price; DROP TABLE products"} {
+		if _, err := models.ParseProductSort(input); !errors.Is(err, models.ErrInvalidSort) {
+			t.Errorf("ParseProductSort(%q) should fail with ErrInvalidSort, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestScheduledPriceInPast(t *testing.T) {
+	scheduled := models.ScheduledPrice{
+		ProductID: 1,
+		Price:     models.Price{Currency: "USD", Amount: decimal.RequireFromString("9.99")},
+		ApplyAt:   time.Now().Add(-time.Minute),
+	}
+
+	// The check happens before the database is touched
+	err := scheduled.Create(nil)
+	if !errors.Is(err, models.ErrScheduledPriceInPast) {
+		t.Errorf("Expected ErrScheduledPriceInPast, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestProductStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.ProductDraft, models.ProductPublished, true},
+		{models.ProductDraft, models.ProductArchived, true},
+		{models.ProductPublished, models.ProductDraft, true},
+		{models.ProductPublished, models.ProductArchived, true},
+		{models.ProductPublished, models.ProductPublished, false},
+		{models.ProductArchived, models.ProductDraft, true},
+		{models.ProductArchived, models.ProductPublished, false},
+		{"deleted", models.ProductDraft, false},
+	}
+
+	for _, tt := range tests {
+		if got := models.CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestSetStatusRejectsInvalidRequests(t *testing.T) {
+	product := models.Product{ID: 1}
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	// These fail before the database is touched
+	err := product.SetStatus(nil, "deleted", nil, 1)
+	if !errors.Is(err, models.ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus, got %v", err)
+	}
+	err = product.SetStatus(nil, models.ProductPublished, &past, 1)
+	if !errors.Is(err, models.ErrPublishAtInPast) {
+		t.Errorf("Expected ErrPublishAtInPast, got %v", err)
+	}
+	err = product.SetStatus(nil, models.ProductArchived, &future, 1)
+	if !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+}
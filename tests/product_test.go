@@ -5,10 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
 	"github.com/yourusername/yourproject/controllers"
 	"github.com/yourusername/yourproject/models"
 	"github.com/yourusername/yourproject/services"
@@ -19,6 +23,7 @@ func TestCreateProduct(t *testing.T) {
 	services.InitLogger()
 	services.InitCache("localhost", "6379")
 	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
 
 	// Create a new product
 	product := models.Product{
@@ -26,7 +31,7 @@ func TestCreateProduct(t *testing.T) {
 		ProductName:        "Test Product",
 		ProductDescription: "This is a test product",
 		ProductImages:      []string{"http://example.com/image1.jpg", "http://example.com/image2.jpg"},
-		ProductPrice:       19.99,
+		ProductPrice:       decimal.RequireFromString("19.99"),
 	}
 
 	// Convert product to JSON
@@ -46,7 +51,7 @@ func TestCreateProduct(t *testing.T) {
 
 	// Create a new router and register the handler
 	router := mux.NewRouter()
-	router.HandleFunc("/products", controllers.CreateProduct).Methods("POST")
+	router.HandleFunc("/products", handler.CreateProduct).Methods("POST")
 
 	// Serve the HTTP request
 	router.ServeHTTP(rr, req)
@@ -73,6 +78,7 @@ func TestGetProductByID(t *testing.T) {
 	services.InitLogger()
 	services.InitCache("localhost", "6379")
 	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
 
 	// Create a new product
 	product := models.Product{
@@ -80,11 +86,12 @@ func TestGetProductByID(t *testing.T) {
 		ProductName:        "Test Product",
 		ProductDescription: "This is a test product",
 		ProductImages:      []string{"http://example.com/image1.jpg", "http://example.com/image2.jpg"},
-		ProductPrice:       19.99,
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
 	}
 
 	// Save the product to the database
-	err := product.Create(services.DB)
+	err := product.Create(services.DB, 0)
 	if err != nil {
 		t.Fatalf("Failed to create product: %v", err)
 	}
@@ -100,7 +107,7 @@ func TestGetProductByID(t *testing.T) {
 
 	// Create a new router and register the handler
 	router := mux.NewRouter()
-	router.HandleFunc("/products/{id}", controllers.GetProductByID).Methods("GET")
+	router.HandleFunc("/products/{id}", handler.GetProductByID).Methods("GET")
 
 	// Serve the HTTP request
 	router.ServeHTTP(rr, req)
@@ -127,6 +134,7 @@ func TestGetAllProducts(t *testing.T) {
 	services.InitLogger()
 	services.InitCache("localhost", "6379")
 	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
 
 	// Create a new product
 	product1 := models.Product{
@@ -134,7 +142,8 @@ func TestGetAllProducts(t *testing.T) {
 		ProductName:        "Test Product 1",
 		ProductDescription: "This is a test product 1",
 		ProductImages:      []string{"http://example.com/image1.jpg", "http://example.com/image2.jpg"},
-		ProductPrice:       19.99,
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
 	}
 
 	product2 := models.Product{
@@ -142,16 +151,17 @@ func TestGetAllProducts(t *testing.T) {
 		ProductName:        "Test Product 2",
 		ProductDescription: "This is a test product 2",
 		ProductImages:      []string{"http://example.com/image3.jpg", "http://example.com/image4.jpg"},
-		ProductPrice:       29.99,
+		ProductPrice:       decimal.RequireFromString("29.99"),
+		Status:             models.ProductPublished,
 	}
 
 	// Save the products to the database
-	err := product1.Create(services.DB)
+	err := product1.Create(services.DB, 0)
 	if err != nil {
 		t.Fatalf("Failed to create product 1: %v", err)
 	}
 
-	err = product2.Create(services.DB)
+	err = product2.Create(services.DB, 0)
 	if err != nil {
 		t.Fatalf("Failed to create product 2: %v", err)
 	}
@@ -167,7 +177,7 @@ func TestGetAllProducts(t *testing.T) {
 
 	// Create a new router and register the handler
 	router := mux.NewRouter()
-	router.HandleFunc("/products", controllers.GetAllProducts).Methods("GET")
+	router.HandleFunc("/products", handler.GetAllProducts).Methods("GET")
 
 	// Serve the HTTP request
 	router.ServeHTTP(rr, req)
@@ -178,14 +188,582 @@ func TestGetAllProducts(t *testing.T) {
 	}
 
 	// Check the response body
-	var products []models.Product
-	err = json.NewDecoder(rr.Body).Decode(&products)
+	var page models.ProductPage
+	err = json.NewDecoder(rr.Body).Decode(&page)
+	if err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+
+	if len(page.Products) != 2 {
+		t.Errorf("Handler returned unexpected number of products: got %v want %v", len(page.Products), 2)
+	}
+}
+
+func TestDeleteAndRestoreProduct(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Create a new product
+	product := models.Product{
+		UserID:             1,
+		ProductName:        "Test Product",
+		ProductDescription: "This is a test product",
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
+	}
+
+	// Save the product to the database
+	err := product.Create(services.DB, 0)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Create a new router and register the handlers
+	router := mux.NewRouter()
+	router.HandleFunc("/products/{id}", handler.GetProductByID).Methods("GET")
+	router.HandleFunc("/products/{id}", handler.DeleteProduct).Methods("DELETE")
+	router.HandleFunc("/products/{id}/restore", handler.RestoreProduct).Methods("POST")
+
+	path := "/products/" + strconv.Itoa(product.ID)
+	steps := []struct {
+		method, path, user string
+		want               int
+	}{
+		{"DELETE", path, "1", http.StatusNoContent},
+		{"GET", path, "1", http.StatusNotFound},
+		{"DELETE", path, "1", http.StatusNotFound},
+		{"POST", path + "/restore", "2", http.StatusForbidden},
+		{"POST", path + "/restore", "", http.StatusForbidden},
+		{"POST", path + "/restore", "1", http.StatusOK},
+		{"GET", path, "1", http.StatusOK},
+		{"POST", path + "/restore", "1", http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		req, err := http.NewRequest(step.method, step.path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("If-Match", "*")
+		if step.user != "" {
+			req.Header.Set("X-User-ID", step.user)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != step.want {
+			t.Errorf("%s %s as user %q returned wrong status code: got %v want %v", step.method, step.path, step.user, status, step.want)
+		}
+	}
+}
+
+func TestProductWritesRequireOwner(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Create a new product
+	product := models.Product{
+		UserID:             1,
+		ProductName:        "Test Product",
+		ProductDescription: "This is a test product",
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
+	}
+
+	// Save the product to the database
+	err := product.Create(services.DB, 0)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Create a new router and register the handlers
+	router := mux.NewRouter()
+	router.HandleFunc("/products/{id}", handler.UpdateProduct).Methods("PUT")
+	router.HandleFunc("/products/{id}", handler.PatchProduct).Methods("PATCH")
+	router.HandleFunc("/products/{id}", handler.DeleteProduct).Methods("DELETE")
+
+	path := "/products/" + strconv.Itoa(product.ID)
+	update := `{"user_id": 1, "product_name": "Renamed", "product_price": "19.99"}`
+	steps := []struct {
+		method, user, body string
+		want               int
+	}{
+		{"PUT", "2", update, http.StatusForbidden},
+		{"PUT", "", update, http.StatusForbidden},
+		{"PATCH", "2", `{"product_name": "Taken"}`, http.StatusForbidden},
+		{"DELETE", "2", "", http.StatusForbidden},
+		{"DELETE", "", "", http.StatusForbidden},
+		{"PUT", "1", update, http.StatusOK},
+		{"PATCH", "1", `{"product_name": "Renamed Again"}`, http.StatusOK},
+		{"DELETE", "1", "", http.StatusNoContent},
+	}
+
+	for _, step := range steps {
+		req, err := http.NewRequest(step.method, path, strings.NewReader(step.body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("If-Match", "*")
+		if step.user != "" {
+			req.Header.Set("X-User-ID", step.user)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != step.want {
+			t.Errorf("%s %s as user %q returned wrong status code: got %v want %v", step.method, path, step.user, status, step.want)
+		}
+	}
+}
+
+func TestTagsAndVariantsFollowProductVisibility(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// A draft, a published product and a trashed one, each with its own tag
+	products := map[string]*models.Product{}
+	for _, status := range []string{models.ProductDraft, models.ProductPublished, "trashed"} {
+		product := models.Product{
+			UserID:             8,
+			ProductName:        "Tagged Product",
+			ProductDescription: "This is a " + status + " product",
+			ProductPrice:       decimal.RequireFromString("19.99"),
+			Status:             models.ProductPublished,
+		}
+		if status == models.ProductDraft {
+			product.Status = models.ProductDraft
+		}
+		err := product.Create(services.DB, 0)
+		if err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+		err = models.AddProductTags(services.DB, product.ID, []string{status + "-tag"})
+		if err != nil {
+			t.Fatalf("Failed to tag product: %v", err)
+		}
+		products[status] = &product
+	}
+	err := products["trashed"].Delete(services.DB)
+	if err != nil {
+		t.Fatalf("Failed to delete product: %v", err)
+	}
+
+	// Create a new router and register the handlers
+	router := mux.NewRouter()
+	router.HandleFunc("/products/{id}/tags", handler.GetProductTags).Methods("GET")
+	router.HandleFunc("/products/{id}/variants", handler.GetProductVariants).Methods("GET")
+	router.HandleFunc("/users/{id}/tags", handler.GetUserTags).Methods("GET")
+
+	draft := "/products/" + strconv.Itoa(products[models.ProductDraft].ID)
+	steps := []struct {
+		path, user string
+		want       int
+	}{
+		{draft + "/tags", "2", http.StatusNotFound},
+		{draft + "/tags", "", http.StatusNotFound},
+		{draft + "/tags", "8", http.StatusOK},
+		{draft + "/variants", "2", http.StatusNotFound},
+		{draft + "/variants", "8", http.StatusOK},
+		{"/products/" + strconv.Itoa(products["trashed"].ID) + "/tags", "8", http.StatusNotFound},
+	}
+	for _, step := range steps {
+		req, err := http.NewRequest("GET", step.path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if step.user != "" {
+			req.Header.Set("X-User-ID", step.user)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != step.want {
+			t.Errorf("GET %s as user %q returned wrong status code: got %v want %v", step.path, step.user, status, step.want)
+		}
+	}
+
+	// Only the owner counts the draft's tag, and nobody the trashed one's
+	for _, step := range []struct {
+		user string
+		want map[string]bool
+	}{
+		{"", map[string]bool{"published-tag": true}},
+		{"2", map[string]bool{"published-tag": true}},
+		{"8", map[string]bool{"published-tag": true, "draft-tag": true}},
+	} {
+		req, err := http.NewRequest("GET", "/users/8/tags", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if step.user != "" {
+			req.Header.Set("X-User-ID", step.user)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var tags []models.TagCount
+		err = json.NewDecoder(rr.Body).Decode(&tags)
+		if err != nil {
+			t.Fatalf("Failed to decode response body: %v", err)
+		}
+		got := map[string]bool{}
+		for _, tag := range tags {
+			got[tag.Tag] = true
+		}
+		for _, tag := range []string{"draft-tag", "published-tag", "trashed-tag"} {
+			if got[tag] != step.want[tag] {
+				t.Errorf("Tags of user 8 as user %q: expected %s listed to be %v, got %+v", step.user, tag, step.want[tag], tags)
+			}
+		}
+	}
+}
+
+func TestProductStatusRequiresOwner(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Create a new draft
+	product := models.Product{
+		UserID:             1,
+		ProductName:        "Test Product",
+		ProductDescription: "This is a test product",
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductDraft,
+	}
+
+	// Save the product to the database
+	err := product.Create(services.DB, 0)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Create a new router and register the handlers
+	router := mux.NewRouter()
+	router.HandleFunc("/products/{id}/publish", handler.PublishProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/unpublish", handler.UnpublishProduct).Methods("POST")
+	router.HandleFunc("/products/{id}/archive", handler.ArchiveProduct).Methods("POST")
+
+	path := "/products/" + strconv.Itoa(product.ID)
+	steps := []struct {
+		path, user string
+		want       int
+	}{
+		{path + "/publish", "2", http.StatusForbidden},
+		{path + "/publish", "", http.StatusForbidden},
+		{path + "/archive", "2", http.StatusForbidden},
+		{path + "/publish", "1", http.StatusOK},
+		{path + "/unpublish", "2", http.StatusForbidden},
+		{path + "/unpublish", "1", http.StatusOK},
+		{path + "/archive", "1", http.StatusOK},
+	}
+
+	for _, step := range steps {
+		req, err := http.NewRequest("POST", step.path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if step.user != "" {
+			req.Header.Set("X-User-ID", step.user)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != step.want {
+			t.Errorf("POST %s as user %q returned wrong status code: got %v want %v", step.path, step.user, status, step.want)
+		}
+	}
+}
+
+func TestRevertProduct(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Create a new product and rename it, making revision 2
+	product := models.Product{
+		UserID:             1,
+		ProductName:        "Test Product",
+		ProductDescription: "This is a test product",
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
+	}
+	err := product.Create(services.DB, 1)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+	err = product.GetByID(services.DB, product.ID)
+	if err != nil {
+		t.Fatalf("Failed to get product: %v", err)
+	}
+	product.ProductName = "Renamed Product"
+	err = product.Update(services.DB, 1)
+	if err != nil {
+		t.Fatalf("Failed to update product: %v", err)
+	}
+
+	// Create a new router and register the handler
+	router := mux.NewRouter()
+	router.HandleFunc("/products/{id}/revisions/{revision}/revert", handler.RevertProduct).Methods("POST")
+
+	path := "/products/" + strconv.Itoa(product.ID) + "/revisions/1/revert"
+	steps := []struct {
+		user, ifMatch string
+		want          int
+	}{
+		{"2", "*", http.StatusForbidden},
+		{"1", "", http.StatusPreconditionRequired},
+		{"1", `"1-0"`, http.StatusPreconditionFailed},
+		{"1", "*", http.StatusOK},
+	}
+
+	var rr *httptest.ResponseRecorder
+	for _, step := range steps {
+		req, err := http.NewRequest("POST", path, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header.Set("X-User-ID", step.user)
+		if step.ifMatch != "" {
+			req.Header.Set("If-Match", step.ifMatch)
+		}
+
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != step.want {
+			t.Errorf("Revert as user %q with If-Match %q returned wrong status code: got %v want %v", step.user, step.ifMatch, status, step.want)
+		}
+	}
+
+	var reverted models.Product
+	err = json.NewDecoder(rr.Body).Decode(&reverted)
 	if err != nil {
 		t.Fatalf("Failed to decode response body: %v", err)
 	}
+	if reverted.ProductName != "Test Product" {
+		t.Errorf("Expected the original name back, got %q", reverted.ProductName)
+	}
+	if rr.Header().Get("ETag") == "" {
+		t.Errorf("Expected an ETag for the reverted product")
+	}
+}
+
+func TestSearchNonEnglishProduct(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Create a product indexed in German
+	product := models.Product{
+		UserID:             1,
+		ProductName:        "Schreibtischlampen",
+		ProductDescription: "Zwei Lampen aus Messing",
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
+		SearchLanguage:     "german",
+	}
+	err := product.Create(services.DB, 0)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Create a new router and register the handler
+	router := mux.NewRouter()
+	router.HandleFunc("/products/search", handler.SearchProducts).Methods("GET")
+
+	// The German stemmer matches the singular to the plural
+	req, err := http.NewRequest("GET", "/products/search?q=Schreibtischlampe&lang=german", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var page models.SearchPage
+	err = json.NewDecoder(rr.Body).Decode(&page)
+	if err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	found := false
+	for _, result := range page.Results {
+		if result.ID == product.ID {
+			found = true
+			if result.SearchLanguage != "german" {
+				t.Errorf("Expected search_language german, got %q", result.SearchLanguage)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected product %d in the German search results, got %+v", product.ID, page.Results)
+	}
+}
+
+func TestListingsConvertCachedPrices(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	product := models.Product{
+		UserID:             7,
+		ProductName:        "Converted Product",
+		ProductDescription: "Listed in pounds",
+		ProductPrice:       decimal.RequireFromString("10.00"),
+		Currency:           "EUR",
+		Status:             models.ProductPublished,
+	}
+	err := product.Create(services.DB, 0)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Create a new router and register the handler
+	router := mux.NewRouter()
+	router.HandleFunc("/products", handler.GetAllProducts).Methods("GET")
+
+	// The second listing is served from the cache, but with the new rate
+	for _, step := range []struct {
+		rate string
+		want string
+	}{
+		{"0.5", "5.00"},
+		{"0.8", "8.00"},
+	} {
+		err := models.SetExchangeRates(services.DB, []models.ExchangeRate{{Base: "EUR", Quote: "GBP", Rate: decimal.RequireFromString(step.rate)}})
+		if err != nil {
+			t.Fatalf("Failed to set exchange rate: %v", err)
+		}
+
+		req, err := http.NewRequest("GET", "/products?user_id=7&currency=GBP", nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+
+		var page models.ProductPage
+		err = json.NewDecoder(rr.Body).Decode(&page)
+		if err != nil {
+			t.Fatalf("Failed to decode response body: %v", err)
+		}
+		found := false
+		for _, p := range page.Products {
+			if p.ID != product.ID {
+				continue
+			}
+			found = true
+			if p.ConvertedPrice == nil || !p.ConvertedPrice.Amount.Equal(decimal.RequireFromString(step.want)) {
+				t.Errorf("Rate %s: expected converted price %s, got %+v", step.rate, step.want, p.ConvertedPrice)
+			}
+		}
+		if !found {
+			t.Errorf("Expected product %d in the listing, got %+v", product.ID, page.Products)
+		}
+	}
+}
+
+func TestProductETags(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Create a new product
+	product := models.Product{
+		UserID:             1,
+		ProductName:        "Test Product",
+		ProductDescription: "This is a test product",
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
+	}
+
+	// Save the product to the database
+	err := product.Create(services.DB, 0)
+	if err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	// Create a new router and register the handlers
+	router := mux.NewRouter()
+	router.HandleFunc("/products/{id}", handler.GetProductByID).Methods("GET")
+	router.HandleFunc("/products/{id}", handler.PatchProduct).Methods("PATCH")
+
+	path := "/products/" + strconv.Itoa(product.ID)
+	serve := func(method string, header http.Header, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		req.Header = header
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("GET", http.Header{}, "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %v %q", rr.Code, etag)
+	}
+
+	rr = serve("GET", http.Header{"If-None-Match": {etag}}, "")
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching If-None-Match, got %v", rr.Code)
+	}
+
+	rr = serve("PATCH", http.Header{"X-User-Id": {"1"}}, `{"product_name": "Renamed"}`)
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected 428 without If-Match, got %v", rr.Code)
+	}
+
+	rr = serve("PATCH", http.Header{"If-Match": {etag}, "X-User-Id": {"1"}}, `{"product_name": "Renamed"}`)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == etag {
+		t.Fatalf("Expected 200 with a new ETag, got %v %q", rr.Code, rr.Header().Get("ETag"))
+	}
 
-	if len(products) != 2 {
-		t.Errorf("Handler returned unexpected number of products: got %v want %v", len(products), 2)
+	// The second editor still holds the old ETag
+	rr = serve("PATCH", http.Header{"If-Match": {etag}, "X-User-Id": {"1"}}, `{"product_name": "Overwritten"}`)
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %v", rr.Code)
+	}
+
+	rr = serve("GET", http.Header{"If-None-Match": {etag}}, "")
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 once the product changed, got %v", rr.Code)
 	}
 }
 
@@ -194,6 +772,7 @@ func BenchmarkGetProductByID(b *testing.B) {
 	services.InitLogger()
 	services.InitCache("localhost", "6379")
 	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
 
 	// Create a new product
 	product := models.Product{
@@ -201,18 +780,19 @@ func BenchmarkGetProductByID(b *testing.B) {
 		ProductName:        "Test Product",
 		ProductDescription: "This is a test product",
 		ProductImages:      []string{"http://example.com/image1.jpg", "http://example.com/image2.jpg"},
-		ProductPrice:       19.99,
+		ProductPrice:       decimal.RequireFromString("19.99"),
+		Status:             models.ProductPublished,
 	}
 
 	// Save the product to the database
-	err := product.Create(services.DB)
+	err := product.Create(services.DB, 0)
 	if err != nil {
 		b.Fatalf("Failed to create product: %v", err)
 	}
 
 	// Create a new router and register the handler
 	router := mux.NewRouter()
-	router.HandleFunc("/products/{id}", controllers.GetProductByID).Methods("GET")
+	router.HandleFunc("/products/{id}", handler.GetProductByID).Methods("GET")
 
 	// Run the benchmark
 	for i := 0; i < b.N; i++ {
@@ -234,3 +814,186 @@ func BenchmarkGetProductByID(b *testing.B) {
 		}
 	}
 }
+
+func TestApplyDuePricesSkipsProductTrashedMeanwhile(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+
+	// Schedule a change for a product that will be trashed, then a later
+	// one for a product that won't
+	applyAt := time.Now().Add(time.Hour)
+	var changes []models.ScheduledPrice
+	var products []models.Product
+	for i, price := range []string{"9.99", "14.99"} {
+		product := models.Product{
+			UserID:             1,
+			ProductName:        "Scheduled Product",
+			ProductDescription: "This is a product with a scheduled price",
+			ProductPrice:       decimal.RequireFromString("19.99"),
+			Status:             models.ProductPublished,
+		}
+		err := product.Create(services.DB, 0)
+		if err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+		change := models.ScheduledPrice{
+			ProductID: product.ID,
+			Price:     models.Price{Amount: decimal.RequireFromString(price), Currency: product.Currency},
+			ApplyAt:   applyAt.Add(time.Duration(i) * time.Second),
+		}
+		err = change.Create(services.DB)
+		if err != nil {
+			t.Fatalf("Failed to schedule price: %v", err)
+		}
+		products = append(products, product)
+		changes = append(changes, change)
+	}
+
+	// Hold the first product's row, as a request trashing it would
+	tx, err := services.DB.Begin()
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("SELECT 1 FROM products WHERE id = $1 FOR UPDATE", products[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to lock product: %v", err)
+	}
+
+	type result struct {
+		applied []models.AppliedPrice
+		err     error
+	}
+	done := make(chan result)
+	go func() {
+		applied, err := models.ApplyDuePrices(services.DB, applyAt.Add(time.Minute), 1000)
+		done <- result{applied, err}
+	}()
+
+	// Trash the product once the scheduler waits for its row
+	for i := 0; i < 200; i++ {
+		var waiting bool
+		query := `SELECT EXISTS (SELECT 1 FROM pg_stat_activity WHERE pid <> pg_backend_pid()
+				  AND wait_event_type = 'Lock' AND query LIKE '%deleted_at IS NULL FOR UPDATE')`
+		err = services.DB.QueryRow(query).Scan(&waiting)
+		if err != nil {
+			t.Fatalf("Failed to check for locks: %v", err)
+		}
+		if waiting {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = tx.Exec("UPDATE products SET deleted_at = NOW() WHERE id = $1", products[0].ID)
+	if err != nil {
+		t.Fatalf("Failed to trash product: %v", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("Failed to apply due prices: %v", res.err)
+	}
+	applied := map[int]bool{}
+	for _, change := range res.applied {
+		applied[change.ID] = true
+	}
+	if applied[changes[0].ID] {
+		t.Errorf("Expected the change of the trashed product to stay pending")
+	}
+	if !applied[changes[1].ID] {
+		t.Errorf("Expected the change after the trashed product's to be applied, got %+v", res.applied)
+	}
+}
+
+func TestSuggestProducts(t *testing.T) {
+	// Initialize the necessary services
+	services.InitLogger()
+	services.InitCache("localhost", "6379")
+	services.InitDB("user=youruser dbname=yourdb sslmode=disable")
+	handler := controllers.NewHandler(services.DB, services.Cache, nil)
+
+	// Names with LIKE wildcards next to names the wildcards would match
+	names := map[string]string{
+		"50% Off Mug":     models.ProductPublished,
+		"50 Percent Mug":  models.ProductPublished,
+		"snake_case Lamp": models.ProductPublished,
+		"snakeXcase Lamp": models.ProductPublished,
+		"Secret Lamp":     models.ProductDraft,
+		"Limit Chair A":   models.ProductPublished,
+		"Limit Chair B":   models.ProductPublished,
+		"Limit Chair C":   models.ProductPublished,
+	}
+	for name, status := range names {
+		product := models.Product{
+			UserID:             9,
+			ProductName:        name,
+			ProductDescription: "This is a product to suggest",
+			ProductPrice:       decimal.RequireFromString("19.99"),
+			Status:             status,
+		}
+		err := product.Create(services.DB, 0)
+		if err != nil {
+			t.Fatalf("Failed to create product: %v", err)
+		}
+	}
+
+	// Create a new router and register the handler
+	router := mux.NewRouter()
+	router.HandleFunc("/products/suggest", handler.SuggestProducts).Methods("GET")
+
+	steps := []struct {
+		prefix, limit, user string
+		want                []string
+	}{
+		{"50%", "", "", []string{"50% Off Mug"}},
+		{"snake_", "", "", []string{"snake_case Lamp"}},
+		{"Secret", "", "", []string{}},
+		{"Secret", "", "2", []string{}},
+		{"Secret", "", "9", []string{"Secret Lamp"}},
+		{"Limit Chair", "2", "", []string{"Limit Chair A", "Limit Chair B"}},
+	}
+	for _, step := range steps {
+		query := url.Values{"user_id": {"9"}, "prefix": {step.prefix}}
+		if step.limit != "" {
+			query.Set("limit", step.limit)
+		}
+		req, err := http.NewRequest("GET", "/products/suggest?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if step.user != "" {
+			req.Header.Set("X-User-ID", step.user)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+		}
+		var response controllers.SuggestResponse
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatalf("Failed to decode response body: %v", err)
+		}
+		if strings.Join(response.Suggestions, "|") != strings.Join(step.want, "|") {
+			t.Errorf("Suggestions for %q as user %q: expected %q, got %q", step.prefix, step.user, step.want, response.Suggestions)
+		}
+	}
+
+	// Limits must be positive
+	req, err := http.NewRequest("GET", "/products/suggest?user_id=9&prefix=Limit&limit=0", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
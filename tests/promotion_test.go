@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestPromotionApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		price     string
+		currency  string
+		want      string
+		ok        bool
+	}{
+		{"percentage", models.Promotion{DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("20")}, "19.99", "USD", "15.99", true},
+		{"percentage rounds half up", models.Promotion{DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("50")}, "0.05", "USD", "0.03", true},
+		{"percentage without decimals", models.Promotion{DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("15")}, "999", "JPY", "849", true},
+		{"fixed", models.Promotion{DiscountType: models.DiscountFixed, Amount: decimal.RequireFromString("5"), Currency: "USD"}, "19.99", "USD", "14.99", true},
+		{"fixed never below zero", models.Promotion{DiscountType: models.DiscountFixed, Amount: decimal.RequireFromString("25"), Currency: "USD"}, "19.99", "USD", "0", true},
+		{"fixed in another currency", models.Promotion{DiscountType: models.DiscountFixed, Amount: decimal.RequireFromString("5"), Currency: "EUR"}, "19.99", "USD", "19.99", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := tt.promotion.Apply(decimal.RequireFromString(tt.price), tt.currency)
+		if ok != tt.ok || !got.Equal(decimal.RequireFromString(tt.want)) {
+			t.Errorf("%s: Apply(%s %s) = %s, %v, want %s, %v", tt.name, tt.price, tt.currency, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPromotionValidate(t *testing.T) {
+	promotion := models.Promotion{
+		Name:         " Summer sale ",
+		DiscountType: models.DiscountFixed,
+		Amount:       decimal.RequireFromString("5"),
+		Currency:     "usd",
+		Tags:         []string{"Clearance", "clearance"},
+	}
+	err := promotion.Validate()
+	if err != nil {
+		t.Fatalf("Expected a valid promotion, got %v", err)
+	}
+	if promotion.Name != "Summer sale" || promotion.Currency != "USD" || len(promotion.Tags) != 1 || promotion.StartsAt.IsZero() {
+		t.Errorf("Expected a normalized promotion, got %+v", promotion)
+	}
+
+	endsAt := time.Now().Add(-time.Hour)
+	invalid := []models.Promotion{
+		{Name: "No discount type", Amount: decimal.RequireFromString("5"), ProductIDs: []int{1}},
+		{Name: "Too much", DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("101"), ProductIDs: []int{1}},
+		{Name: "No currency", DiscountType: models.DiscountFixed, Amount: decimal.RequireFromString("5"), ProductIDs: []int{1}},
+		{Name: "Too precise", DiscountType: models.DiscountFixed, Amount: decimal.RequireFromString("0.5"), Currency: "JPY", ProductIDs: []int{1}},
+		{Name: "No targets", DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("10")},
+		{Name: "Ends before it starts", DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("10"), EndsAt: &endsAt, ProductIDs: []int{1}},
+		{DiscountType: models.DiscountPercentage, Amount: decimal.RequireFromString("10"), ProductIDs: []int{1}},
+	}
+	for _, p := range invalid {
+		err := p.Validate()
+		if !errors.Is(err, models.ErrInvalidPromotion) {
+			t.Errorf("%q: expected ErrInvalidPromotion, got %v", p.Name, err)
+		}
+	}
+}
+
+func TestPromotionActive(t *testing.T) {
+	now := time.Now()
+	endsAt := now.Add(time.Hour)
+	promotion := models.Promotion{StartsAt: now, EndsAt: &endsAt}
+
+	if !promotion.Active(now) || promotion.Active(now.Add(-time.Second)) || promotion.Active(endsAt) {
+		t.Errorf("Expected the promotion to run from StartsAt until just before EndsAt")
+	}
+}
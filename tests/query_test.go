@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestProductFilterNumbersPlaceholders(t *testing.T) {
+	// Only max_price and product_name: placeholders must still be $1..$n
+	b := &models.QueryBuilder{}
+	models.ProductFilter{UserID: 1, MaxPrice: decimal.NewFromInt(50), ProductName: "shoe"}.Apply(b)
+
+	want := " WHERE user_id = $1 AND COALESCE(promo.price, products.product_price) <= $2 AND product_name ILIKE $3"
+	if got := b.WhereClause(); got != want {
+		t.Errorf("Unexpected WHERE clause:\ngot  %q\nwant %q", got, want)
+	}
+	if len(b.Args()) != 3 {
+		t.Errorf("Unexpected number of args: got %v want %v", len(b.Args()), 3)
+	}
+}
+
+func TestProductFilterAllFilters(t *testing.T) {
+	hasImages := true
+	filter := models.ProductFilter{
+		UserID:        1,
+		MinPrice:      decimal.NewFromInt(10),
+		MaxPrice:      decimal.NewFromInt(50),
+		ProductName:   "shoe",
+		Description:   "leather",
+		HasImages:     &hasImages,
+		CreatedAfter:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedBefore: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		IDs:           []int{1, 2, 3},
+	}
+
+	b := &models.QueryBuilder{}
+	filter.Apply(b)
+
+	want := " WHERE user_id = $1 AND COALESCE(promo.price, products.product_price) >= $2 AND COALESCE(promo.price, products.product_price) <= $3" +
+		" AND product_name ILIKE $4 AND product_description ILIKE $5" +
+		" AND cardinality(product_images) > 0" +
+		" AND created_at >= $6 AND created_at < $7 AND id = ANY($8)"
+	if got := b.WhereClause(); got != want {
+		t.Errorf("Unexpected WHERE clause:\ngot  %q\nwant %q", got, want)
+	}
+	if len(b.Args()) != 8 {
+		t.Errorf("Unexpected number of args: got %v want %v", len(b.Args()), 8)
+	}
+}
+
+func TestQueryBuilderWithoutConditions(t *testing.T) {
+	b := &models.QueryBuilder{}
+	if got := b.WhereClause(); got != "" {
+		t.Errorf("Expected empty WHERE clause, got %q", got)
+	}
+}
+
+func TestProductFilterCategoryIncludesDescendants(t *testing.T) {
+	b := &models.QueryBuilder{}
+	models.ProductFilter{UserID: 1, CategoryID: 7}.Apply(b)
+
+	where := b.WhereClause()
+	if !strings.Contains(where, "WITH RECURSIVE") || !strings.Contains(where, "WHERE id = $2") {
+		t.Errorf("Expected a recursive category subtree bound to $2, got %q", where)
+	}
+	if args := b.Args(); len(args) != 2 || args[1] != 7 {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
+
+func TestProductFilterTags(t *testing.T) {
+	b := &models.QueryBuilder{}
+	models.ProductFilter{UserID: 1, Tags: []string{"clearance", "new-arrival"}}.Apply(b)
+	want := " WHERE user_id = $1 AND id IN (SELECT product_id FROM product_tags WHERE tag = ANY($2))"
+	if got := b.WhereClause(); got != want {
+		t.Errorf("Unexpected WHERE clause:\ngot  %q\nwant %q", got, want)
+	}
+
+	// All tags: products must carry as many of the tags as were asked for
+	b = &models.QueryBuilder{}
+	models.ProductFilter{UserID: 1, Tags: []string{"clearance", "new-arrival"}, AllTags: true}.Apply(b)
+	want = " WHERE user_id = $1 AND id IN (SELECT product_id FROM product_tags WHERE tag = ANY($2) GROUP BY product_id HAVING COUNT(*) = $3)"
+	if got := b.WhereClause(); got != want {
+		t.Errorf("Unexpected WHERE clause:\ngot  %q\nwant %q", got, want)
+	}
+	if args := b.Args(); len(args) != 3 || args[2] != 2 {
+		t.Errorf("Unexpected args: %v", args)
+	}
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	a := models.ProductSnapshot{
+		UserID:        1,
+		ProductName:   "Sample Product",
+		ProductImages: []string{"http://example.com/image1.jpg"},
+		ProductPrice:  decimal.RequireFromString("19.99"),
+		Currency:      "USD",
+	}
+	b := a
+	b.ProductName = "Renamed Product"
+	b.ProductImages = []string{"http://example.com/image1.jpg", "http://example.com/image2.jpg"}
+	b.ProductPrice = decimal.RequireFromString("19.990")
+
+	changes := models.DiffSnapshots(a, b)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes)
+	}
+	if changes[0].Field != "product_name" || changes[0].From != "Sample Product" || changes[0].To != "Renamed Product" {
+		t.Errorf("Unexpected name change %+v", changes[0])
+	}
+	if changes[1].Field != "product_images" {
+		t.Errorf("Expected product_images to change, got %+v", changes[1])
+	}
+}
+
+func TestDiffSnapshotsUnchanged(t *testing.T) {
+	a := models.ProductSnapshot{ProductName: "Sample Product", ProductPrice: decimal.RequireFromString("5"), Currency: "EUR"}
+	if changes := models.DiffSnapshots(a, a); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestSearchProductsValidatesOptions(t *testing.T) {
+	// Validation happens before the database is used
+	_, err := models.SearchProducts(nil, models.SearchOptions{Query: "shoe", Language: "klingon"})
+	if !errors.Is(err, models.ErrInvalidSearchLanguage) {
+		t.Errorf("Expected ErrInvalidSearchLanguage, got %v", err)
+	}
+
+	_, err = models.SearchProducts(nil, models.SearchOptions{Query: "   "})
+	if !errors.Is(err, models.ErrEmptySearchQuery) {
+		t.Errorf("Expected ErrEmptySearchQuery, got %v", err)
+	}
+
+	// Prefix queries drop tsquery operators, leaving nothing to search for
+	_, err = models.SearchProducts(nil, models.SearchOptions{Query: "&|!:*", Prefix: true})
+	if !errors.Is(err, models.ErrEmptySearchQuery) {
+		t.Errorf("Expected ErrEmptySearchQuery, got %v", err)
+	}
+}
+
+func TestProductValidatesSearchLanguage(t *testing.T) {
+	product := models.Product{ProductPrice: decimal.RequireFromString("19.99")}
+	if err := product.Validate(); err != nil || product.SearchLanguage != models.DefaultSearchLanguage {
+		t.Errorf("Expected the default search language, got %q, %v", product.SearchLanguage, err)
+	}
+
+	product.SearchLanguage = "german"
+	if err := product.Validate(); err != nil || product.SearchLanguage != "german" {
+		t.Errorf("Expected german to be kept, got %q, %v", product.SearchLanguage, err)
+	}
+
+	// Postgres has no text search configuration of that name
+	product.SearchLanguage = "klingon"
+	if err := product.Validate(); !errors.Is(err, models.ErrInvalidSearchLanguage) {
+		t.Errorf("Expected ErrInvalidSearchLanguage, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+		err  bool
+	}{
+		{"clearance", "clearance", false},
+		{"  New-Arrival ", "new-arrival", false},
+		{"size_42", "size_42", false},
+		{"", "", true},
+		{"-sale", "", true},
+		{"two words", "", true},
+		{"a,b", "", true},
+		{strings.Repeat("a", models.MaxTagLength+1), "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := models.NormalizeTag(tt.tag)
+		if tt.err {
+			if !errors.Is(err, models.ErrInvalidTag) {
+				t.Errorf("NormalizeTag(%q): expected ErrInvalidTag, got %v", tt.tag, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeTag(%q) = %q, %v; want %q", tt.tag, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeTagsDropsDuplicates(t *testing.T) {
+	got, err := models.NormalizeTags([]string{"Sale", "sale ", "new"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"sale", "new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected tags: got %v want %v", got, want)
+	}
+}
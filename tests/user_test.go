@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -20,10 +21,11 @@ func TestCreateUser(t *testing.T) {
 		t.Fatalf("Failed to connect to the database: %v", err)
 	}
 	defer db.Close()
+	handler := controllers.NewHandler(db, nil, nil)
 
 	// Set up the router
 	router := mux.NewRouter()
-	router.HandleFunc("/users", controllers.CreateUser).Methods("POST")
+	router.HandleFunc("/users", handler.CreateUser).Methods("POST")
 
 	// Create a new user
 	user := models.User{
@@ -63,10 +65,11 @@ func TestGetUserByID(t *testing.T) {
 		t.Fatalf("Failed to connect to the database: %v", err)
 	}
 	defer db.Close()
+	handler := controllers.NewHandler(db, nil, nil)
 
 	// Set up the router
 	router := mux.NewRouter()
-	router.HandleFunc("/users/{id}", controllers.GetUserByID).Methods("GET")
+	router.HandleFunc("/users/{id}", handler.GetUserByID).Methods("GET")
 
 	// Create a new user
 	user := models.User{
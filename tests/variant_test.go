@@ -0,0 +1,41 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/yourusername/yourproject/models"
+)
+
+func TestVariantValidate(t *testing.T) {
+	product := models.Product{
+		ProductImages: []string{"http://example.com/a.jpg", "http://example.com/b.jpg"},
+		Currency:      "USD",
+	}
+	negative := decimal.NewFromInt(-1)
+	fractionalCent := decimal.RequireFromString("9.999")
+
+	tests := []struct {
+		name    string
+		variant models.Variant
+		err     error
+	}{
+		{"valid", models.Variant{SKU: "SKU-1", Images: []string{"http://example.com/b.jpg"}}, nil},
+		{"missing SKU", models.Variant{}, models.ErrInvalidSKU},
+		{"long SKU", models.Variant{SKU: strings.Repeat("x", models.MaxSKULength+1)}, models.ErrInvalidSKU},
+		{"negative price", models.Variant{SKU: "SKU-1", PriceOverride: &negative}, models.ErrInvalidVariantPrice},
+		{"price scale", models.Variant{SKU: "SKU-1", PriceOverride: &fractionalCent}, models.ErrInvalidVariantPrice},
+		{"foreign image", models.Variant{SKU: "SKU-1", Images: []string{"http://example.com/c.jpg"}}, models.ErrInvalidVariantImage},
+	}
+
+	for _, tt := range tests {
+		err := tt.variant.Validate(product)
+		if tt.err == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		} else if tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+		}
+	}
+}